@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// benchWarmup is how many frames are rendered before a benchmark starts
+// measuring, while drivers compile and caches fill.
+const benchWarmup = 30
+
+// exitRegression is the exit status of benchmarks that regressed, apart from
+// the status 1 of benchmarks that failed to run and the status 2 of invalid
+// flags.
+const exitRegression = 3
+
+// benchResult is what a benchmark writes, and what later benchmarks compare
+// against. Timings are in milliseconds.
+type benchResult struct {
+	Shader     string             `json:"shader"`
+	Renderer   string             `json:"renderer"`
+	Resolution string             `json:"resolution"`
+	Time       float64            `json:"time"`
+	Frames     int                `json:"frames"`
+	Params     map[string]float32 `json:"params,omitempty"`
+	Frame      timingSummary      `json:"frame"`
+	CPU        timingSummary      `json:"cpu"`
+	GPU        timingSummary      `json:"gpu"`
+}
+
+// runBenchmark renders the pass graph a fixed number of frames with the
+// starting camera, at the start time and at render resolution, and writes
+// the frame timing statistics to the benchmark file. Frames are never
+// presented, so vsync cannot hold them back. It returns whether the result
+// regressed compared to the baseline file, if there is one. Baselines of
+// another shader or resolution are refused.
+func runBenchmark(flags *flags, passes []*renderPass, params *paramSet, vao uint32) (bool, error) {
+	var baseline *benchResult
+	if flags.BenchBaseline() != "" {
+		var err error
+		if baseline, err = loadBenchResult(flags.BenchBaseline()); err != nil {
+			return false, err
+		}
+	}
+
+	state := newCamera(flags.Fov()).frameState()
+	state.time = float32(wrapTime(flags.Start(), flags.Loop()))
+	state.timeDelta = float32(1 / flags.Fps())
+	state.frameRate = float32(flags.Fps())
+	state.date = shadertoyDate(renderEpoch.Add(time.Duration(flags.Start() * float64(time.Second))))
+	state.params = params.values
+
+	timer := newFrameTimer()
+	var timings []frameTiming
+	var frameTimes []float64
+	for frame := range benchWarmup + flags.BenchFrames() {
+		start := time.Now()
+		state.frame = frame
+		timer.begin(frame, state.time, 0)
+		timer.beginSection(timerPasses)
+		for _, pass := range passes {
+			pass.render(vao, state)
+		}
+		timer.endSection()
+		timer.end(time.Since(start).Seconds())
+		// Waiting for the GPU makes the frame time cover all of its work
+		gl.Finish()
+		if frame >= benchWarmup {
+			frameTimes = append(frameTimes, time.Since(start).Seconds()*1000)
+		}
+		timings = append(timings, timer.poll()...)
+	}
+	timings = append(timings, timer.flush()...)
+
+	var cpuTimes, gpuTimes []float64
+	for _, timing := range timings {
+		if timing.frame >= benchWarmup {
+			cpuTimes = append(cpuTimes, timing.cpu)
+			gpuTimes = append(gpuTimes, timing.passes)
+		}
+	}
+
+	image := passes[len(passes)-1]
+	result := &benchResult{
+		Shader:     benchShaderPath(flags.Frag()),
+		Renderer:   gl.GoStr(gl.GetString(gl.RENDERER)),
+		Resolution: fmt.Sprintf("%dx%d", image.target.width, image.target.height),
+		Time:       flags.Start(),
+		Frames:     flags.BenchFrames(),
+		Params:     params.values,
+		Frame:      summarizeTimings(frameTimes),
+		CPU:        summarizeTimings(cpuTimes),
+		GPU:        summarizeTimings(gpuTimes),
+	}
+	log.Printf("Benchmarked %s at %s on %s\n", result.Shader, result.Resolution, result.Renderer)
+	for _, line := range result.lines() {
+		log.Println(line)
+	}
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return false, fmt.Errorf("failed to encode benchmark result: %w", err)
+	}
+	if err := os.WriteFile(flags.Bench(), data, 0o644); err != nil {
+		return false, fmt.Errorf("failed to write benchmark file: %w", err)
+	}
+
+	if baseline == nil {
+		return false, nil
+	}
+	if err := checkBenchBaseline(baseline, result); err != nil {
+		return false, err
+	}
+	if baseline.Renderer != result.Renderer {
+		log.Printf("Baseline was benchmarked on %s, the comparison may not be meaningful\n", baseline.Renderer)
+	}
+	regressions := compareBenchResults(baseline, result, flags.BenchThreshold())
+	for _, regression := range regressions {
+		log.Printf("Regression: %s\n", regression)
+	}
+	if len(regressions) == 0 {
+		log.Printf("No regressions beyond %g%% compared to %s\n", flags.BenchThreshold(), flags.BenchBaseline())
+	}
+	return len(regressions) > 0, nil
+}
+
+func loadBenchResult(path string) (*benchResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read benchmark baseline file: %w", err)
+	}
+	var result benchResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse benchmark baseline file %s: %w", path, err)
+	}
+	return &result, nil
+}
+
+func (r *benchResult) lines() []string {
+	lines := []string{fmt.Sprintf("%-5s %8s %8s %8s %8s", "ms", "min", "avg", "p95", "p99")}
+	for _, row := range []struct {
+		name    string
+		summary timingSummary
+	}{{"frame", r.Frame}, {"cpu", r.CPU}, {"gpu", r.GPU}} {
+		lines = append(lines, fmt.Sprintf("%-5s %8.3f %8.3f %8.3f %8.3f", row.name, row.summary.Min, row.summary.Avg, row.summary.P95, row.summary.P99))
+	}
+	return lines
+}
+
+// benchShaderPath names the shader at path relative to the working
+// directory, so that benchmarks run from the root of different checkouts
+// name the same shader alike however it was given.
+func benchShaderPath(path string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if absolute, err := filepath.Abs(path); err == nil {
+			if relative, err := filepath.Rel(wd, absolute); err == nil {
+				path = relative
+			}
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// checkBenchBaseline returns an error if baseline benchmarked another shader
+// or another resolution than result, whose timings cannot be compared.
+func checkBenchBaseline(baseline, result *benchResult) error {
+	if baseline.Shader != result.Shader {
+		return fmt.Errorf("error: Benchmark baseline is of shader %s, not %s", baseline.Shader, result.Shader)
+	}
+	if baseline.Resolution != result.Resolution {
+		return fmt.Errorf("error: Benchmark baseline was rendered at %s, not %s", baseline.Resolution, result.Resolution)
+	}
+	return nil
+}
+
+// compareBenchResults describes the average and 95th percentile frame and
+// GPU times of result that are more than threshold percent slower than
+// those of baseline. The minimum and 99th percentile are too noisy to
+// compare.
+func compareBenchResults(baseline, result *benchResult, threshold float64) []string {
+	var regressions []string
+	for _, metric := range []struct {
+		name             string
+		baseline, result float64
+	}{
+		{"frame avg", baseline.Frame.Avg, result.Frame.Avg},
+		{"frame p95", baseline.Frame.P95, result.Frame.P95},
+		{"gpu avg", baseline.GPU.Avg, result.GPU.Avg},
+		{"gpu p95", baseline.GPU.P95, result.GPU.P95},
+	} {
+		if metric.baseline <= 0 {
+			continue
+		}
+		change := (metric.result/metric.baseline - 1) * 100
+		if change > threshold {
+			regressions = append(regressions, fmt.Sprintf("%s %.3f ms -> %.3f ms (%+.1f%%)", metric.name, metric.baseline, metric.result, change))
+		}
+	}
+	return regressions
+}
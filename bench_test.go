@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestCompareBenchResults(t *testing.T) {
+	baseline := &benchResult{
+		Frame: timingSummary{Avg: 10, P95: 12},
+		GPU:   timingSummary{Avg: 8, P95: 0},
+	}
+	tests := []struct {
+		name      string
+		result    benchResult
+		threshold float64
+		want      []string
+	}{
+		{
+			name:      "faster",
+			result:    benchResult{Frame: timingSummary{Avg: 9, P95: 11}, GPU: timingSummary{Avg: 7}},
+			threshold: 5,
+		},
+		{
+			name:      "slower within threshold",
+			result:    benchResult{Frame: timingSummary{Avg: 10.4, P95: 12.5}, GPU: timingSummary{Avg: 8.3}},
+			threshold: 5,
+		},
+		{
+			name:      "slower by exactly the threshold",
+			result:    benchResult{Frame: timingSummary{Avg: 12.5, P95: 12}, GPU: timingSummary{Avg: 8}},
+			threshold: 25,
+		},
+		{
+			name:      "slower beyond threshold",
+			result:    benchResult{Frame: timingSummary{Avg: 11, P95: 15}, GPU: timingSummary{Avg: 8}},
+			threshold: 5,
+			want:      []string{"frame avg 10.000 ms -> 11.000 ms (+10.0%)", "frame p95 12.000 ms -> 15.000 ms (+25.0%)"},
+		},
+		{
+			name:      "zero threshold",
+			result:    benchResult{Frame: timingSummary{Avg: 10, P95: 12}, GPU: timingSummary{Avg: 8.08}},
+			threshold: 0,
+			want:      []string{"gpu avg 8.000 ms -> 8.080 ms (+1.0%)"},
+		},
+		{
+			name:      "missing baseline timings are skipped",
+			result:    benchResult{Frame: timingSummary{Avg: 10, P95: 12}, GPU: timingSummary{Avg: 8, P95: 100}},
+			threshold: 5,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := compareBenchResults(baseline, &test.result, test.threshold)
+			if !slices.Equal(got, test.want) {
+				t.Errorf("regressions = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckBenchBaseline(t *testing.T) {
+	result := &benchResult{Shader: "shaders/gopher.frag", Renderer: "llvmpipe", Resolution: "800x600"}
+	tests := []struct {
+		name     string
+		baseline benchResult
+		wantErr  bool
+	}{
+		{"same benchmark", benchResult{Shader: "shaders/gopher.frag", Renderer: "llvmpipe", Resolution: "800x600"}, false},
+		{"other renderer", benchResult{Shader: "shaders/gopher.frag", Renderer: "NVIDIA", Resolution: "800x600"}, false},
+		{"other shader", benchResult{Shader: "shaders/menger.frag", Renderer: "llvmpipe", Resolution: "800x600"}, true},
+		{"other resolution", benchResult{Shader: "shaders/gopher.frag", Renderer: "llvmpipe", Resolution: "1920x1080"}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkBenchBaseline(&test.baseline, result)
+			if (err != nil) != test.wantErr {
+				t.Errorf("error = %v, want error %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestBenchShaderPath(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"shaders/gopher.frag", "shaders/gopher.frag"},
+		{"./shaders//gopher.frag", "shaders/gopher.frag"},
+		{filepath.Join(wd, "shaders", "gopher.frag"), "shaders/gopher.frag"},
+		{filepath.Join(filepath.Dir(wd), "gopher.frag"), "../gopher.frag"},
+	}
+	for _, test := range tests {
+		if got := benchShaderPath(test.path); got != test.want {
+			t.Errorf("benchShaderPath(%q) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
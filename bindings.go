@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// keyBinding is a key that triggers an action while the modifiers are held,
+// or a gamepad button.
+type keyBinding struct {
+	key       glfw.Key
+	modifiers glfw.ModifierKey
+	gamepad   bool
+	button    glfw.GamepadButton
+}
+
+// defaultBindings are the controls used for actions a bindings file does not
+// rebind.
+var defaultBindings = map[string][]string{
+	"quit":              {"escape"},
+	"move-forward":      {"w"},
+	"move-backward":     {"s"},
+	"move-left":         {"a"},
+	"move-right":        {"d"},
+	"move-up":           {"space", "gamepad-right-thumb"},
+	"move-down":         {"left-shift", "gamepad-left-thumb"},
+	"move-slow":         {"left-control"},
+	"roll-left":         {"z", "gamepad-x"},
+	"roll-right":        {"c", "gamepad-b"},
+	"speed-down":        {"q", "gamepad-left-bumper"},
+	"speed-up":          {"e", "gamepad-right-bumper"},
+	"slider-x-dec":      {"kp-subtract", "minus", "gamepad-dpad-left"},
+	"slider-x-inc":      {"kp-add", "equal", "gamepad-dpad-right"},
+	"slider-y-dec":      {"down", "gamepad-dpad-down"},
+	"slider-y-inc":      {"up", "gamepad-dpad-up"},
+	"slider-z-dec":      {"left"},
+	"slider-z-inc":      {"right"},
+	"slider-w-dec":      {"page-down"},
+	"slider-w-inc":      {"page-up"},
+	"free-flight":       {"f", "gamepad-y"},
+	"keyframe-add":      {"k"},
+	"keyframe-remove":   {"backspace"},
+	"path-play":         {"p"},
+	"screenshot":        {"f12"},
+	"hud":               {"f1"},
+	"time-pause":        {"t", "gamepad-start"},
+	"time-rewind":       {"r"},
+	"time-step-back":    {"y"},
+	"time-step-forward": {"u"},
+	"time-rate-down":    {"g"},
+	"time-rate-up":      {"h"},
+	"time-rate-reset":   {"b"},
+	"param-prev":        {"left-bracket"},
+	"param-next":        {"right-bracket"},
+	"param-dec":         {"comma"},
+	"param-inc":         {"period"},
+}
+
+func init() {
+	for slot := 1; slot <= bookmarkSlots; slot++ {
+		defaultBindings[fmt.Sprintf("bookmark-recall-%d", slot)] = []string{fmt.Sprint(slot)}
+		defaultBindings[fmt.Sprintf("bookmark-store-%d", slot)] = []string{fmt.Sprintf("ctrl+%d", slot)}
+	}
+}
+
+var modifierNames = map[string]glfw.ModifierKey{
+	"shift": glfw.ModShift,
+	"ctrl":  glfw.ModControl,
+	"alt":   glfw.ModAlt,
+	"super": glfw.ModSuper,
+}
+
+// keyNames name keys by their physical position on a US keyboard, which is
+// what GLFW key codes refer to whatever the keyboard layout. Bindings thus
+// stay where they are on the keyboard when the layout changes.
+var keyNames = func() map[string]glfw.Key {
+	names := map[string]glfw.Key{
+		"space": glfw.KeySpace, "apostrophe": glfw.KeyApostrophe, "comma": glfw.KeyComma,
+		"minus": glfw.KeyMinus, "period": glfw.KeyPeriod, "slash": glfw.KeySlash,
+		"semicolon": glfw.KeySemicolon, "equal": glfw.KeyEqual, "left-bracket": glfw.KeyLeftBracket,
+		"backslash": glfw.KeyBackslash, "right-bracket": glfw.KeyRightBracket, "grave-accent": glfw.KeyGraveAccent,
+		"escape": glfw.KeyEscape, "enter": glfw.KeyEnter, "tab": glfw.KeyTab, "backspace": glfw.KeyBackspace,
+		"insert": glfw.KeyInsert, "delete": glfw.KeyDelete, "right": glfw.KeyRight, "left": glfw.KeyLeft,
+		"down": glfw.KeyDown, "up": glfw.KeyUp, "page-up": glfw.KeyPageUp, "page-down": glfw.KeyPageDown,
+		"home": glfw.KeyHome, "end": glfw.KeyEnd, "caps-lock": glfw.KeyCapsLock,
+		"kp-decimal": glfw.KeyKPDecimal, "kp-divide": glfw.KeyKPDivide, "kp-multiply": glfw.KeyKPMultiply,
+		"kp-subtract": glfw.KeyKPSubtract, "kp-add": glfw.KeyKPAdd, "kp-enter": glfw.KeyKPEnter,
+		"left-shift": glfw.KeyLeftShift, "left-control": glfw.KeyLeftControl, "left-alt": glfw.KeyLeftAlt,
+		"left-super": glfw.KeyLeftSuper, "right-shift": glfw.KeyRightShift, "right-control": glfw.KeyRightControl,
+		"right-alt": glfw.KeyRightAlt, "right-super": glfw.KeyRightSuper,
+	}
+	for c := 'a'; c <= 'z'; c++ {
+		names[string(c)] = glfw.KeyA + glfw.Key(c-'a')
+	}
+	for n := range 10 {
+		names[fmt.Sprint(n)] = glfw.Key0 + glfw.Key(n)
+		names[fmt.Sprintf("kp-%d", n)] = glfw.KeyKP0 + glfw.Key(n)
+	}
+	for n := 1; n <= 25; n++ {
+		names[fmt.Sprintf("f%d", n)] = glfw.KeyF1 + glfw.Key(n-1)
+	}
+	return names
+}()
+
+// parseKeyBinding parses a key name optionally preceded by modifiers, as in
+// "ctrl+shift+s", or a gamepad button name such as "gamepad-a".
+func parseKeyBinding(binding string) (keyBinding, error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(binding)), "+")
+	var parsed keyBinding
+	for _, modifier := range parts[:len(parts)-1] {
+		mod, ok := modifierNames[modifier]
+		if !ok {
+			return parsed, fmt.Errorf("error: Unknown modifier %q in %q, expected shift, ctrl, alt or super", modifier, binding)
+		}
+		parsed.modifiers |= mod
+	}
+	if button, ok := gamepadButtonNames[parts[len(parts)-1]]; ok {
+		parsed.gamepad, parsed.button = true, button
+		return parsed, nil
+	}
+	key, ok := keyNames[parts[len(parts)-1]]
+	if !ok {
+		return parsed, fmt.Errorf("error: Unknown key %q in %q", parts[len(parts)-1], binding)
+	}
+	parsed.key = key
+	return parsed, nil
+}
+
+// loadBindings returns the default bindings with the actions listed in the
+// JSON bindings file at path, if any, rebound. The file maps action names to
+// lists of keys, an empty list unbinding the action. Keys are named by their
+// physical position on a US keyboard, not by what the active layout prints
+// on them: "w" is the key labeled Z on AZERTY keyboards.
+func loadBindings(path string) (map[string][]keyBinding, error) {
+	bindings := map[string][]string{}
+	for action, keys := range defaultBindings {
+		bindings[action] = keys
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error: Bindings file could not be read:\n\t%s", err.Error())
+		}
+		var rebound map[string][]string
+		if err := json.Unmarshal(data, &rebound); err != nil {
+			return nil, fmt.Errorf("error: Bindings file could not be parsed:\n\t%s", err.Error())
+		}
+		for action, keys := range rebound {
+			if _, ok := defaultBindings[action]; !ok {
+				return nil, fmt.Errorf("error: Unknown action %q in bindings file, expected one of %s", action, strings.Join(actionNames(), ", "))
+			}
+			bindings[action] = keys
+		}
+	}
+
+	parsed := map[string][]keyBinding{}
+	for action, keys := range bindings {
+		for _, key := range keys {
+			binding, err := parseKeyBinding(key)
+			if err != nil {
+				return nil, err
+			}
+			parsed[action] = append(parsed[action], binding)
+		}
+	}
+	return parsed, nil
+}
+
+func actionNames() []string {
+	names := make([]string, 0, len(defaultBindings))
+	for action := range defaultBindings {
+		names = append(names, action)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+func TestParseKeyBinding(t *testing.T) {
+	tests := []struct {
+		binding string
+		want    keyBinding
+		wantErr string
+	}{
+		{binding: "w", want: keyBinding{key: glfw.KeyW}},
+		{binding: " Ctrl+Shift+S ", want: keyBinding{key: glfw.KeyS, modifiers: glfw.ModControl | glfw.ModShift}},
+		{binding: "ctrl+1", want: keyBinding{key: glfw.Key1, modifiers: glfw.ModControl}},
+		{binding: "kp-add", want: keyBinding{key: glfw.KeyKPAdd}},
+		{binding: "f12", want: keyBinding{key: glfw.KeyF12}},
+		{binding: "gamepad-dpad-up", want: keyBinding{gamepad: true, button: glfw.ButtonDpadUp}},
+		{binding: "hyper+a", wantErr: "error: Unknown modifier \"hyper\" in \"hyper+a\", expected shift, ctrl, alt or super"},
+		{binding: "ctrl+", wantErr: "error: Unknown key \"\" in \"ctrl+\""},
+		{binding: "é", wantErr: "error: Unknown key \"é\" in \"é\""},
+	}
+	for _, test := range tests {
+		t.Run(test.binding, func(t *testing.T) {
+			got, err := parseKeyBinding(test.binding)
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Errorf("error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil || got != test.want {
+				t.Errorf("parseKeyBinding = %+v, %v, want %+v", got, err, test.want)
+			}
+		})
+	}
+}
+
+func TestLoadBindings(t *testing.T) {
+	defaults, err := loadBindings("")
+	if err != nil {
+		t.Fatalf("default bindings: %v", err)
+	}
+	if len(defaults) != len(defaultBindings) {
+		t.Errorf("default bindings bind %d actions, want %d", len(defaults), len(defaultBindings))
+	}
+
+	path := filepath.Join(t.TempDir(), "bindings.json")
+	if err := os.WriteFile(path, []byte(`{"move-forward": ["up", "gamepad-a"], "quit": []}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bindings, err := loadBindings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bindings["move-forward"]; len(got) != 2 || got[0].key != glfw.KeyUp || got[1].button != glfw.ButtonA {
+		t.Errorf("move-forward = %+v, want up and gamepad-a", got)
+	}
+	if got := bindings["quit"]; len(got) != 0 {
+		t.Errorf("quit = %+v, want unbound", got)
+	}
+	if got := bindings["move-backward"]; len(got) != 1 || got[0].key != glfw.KeyS {
+		t.Errorf("move-backward = %+v, want its default", got)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"fly": ["w"]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadBindings(path); err == nil {
+		t.Error("unknown action loaded without error")
+	}
+}
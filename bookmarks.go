@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const bookmarkSlots = 9
+
+// bookmarks are camera states saved per shader, in a JSON file next to it so
+// they can be shared along with the shader.
+type bookmarks struct {
+	path  string
+	slots map[int]camera
+}
+
+func bookmarksPath(shaderPath string) string {
+	return strings.TrimSuffix(shaderPath, filepath.Ext(shaderPath)) + ".bookmarks.json"
+}
+
+// loadBookmarks reads the bookmarks file at path. A missing file is not an
+// error, it just holds no bookmarks yet.
+func loadBookmarks(path string) (*bookmarks, error) {
+	b := &bookmarks{path: path, slots: map[int]camera{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bookmarks file: %w", err)
+	}
+	if err := json.Unmarshal(data, &b.slots); err != nil {
+		return nil, fmt.Errorf("failed to parse bookmarks file %s: %w", path, err)
+	}
+	return b, nil
+}
+
+func (b *bookmarks) get(slot int) (camera, bool) {
+	c, ok := b.slots[slot]
+	return c, ok
+}
+
+// set stores c in slot and writes all bookmarks back to disk.
+func (b *bookmarks) set(slot int, c camera) error {
+	b.slots[slot] = c
+	data, err := json.MarshalIndent(b.slots, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode bookmarks: %w", err)
+	}
+	if err := os.WriteFile(b.path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write bookmarks file: %w", err)
+	}
+	return nil
+}
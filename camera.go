@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/chewxy/math32"
+)
+
+const startCameraPitch = math.Pi / 2
+
+// Vertical fields of view, in radians
+const (
+	defaultFov = math.Pi / 3
+	minFov     = math.Pi / 180
+	maxFov     = math.Pi * 170 / 180
+)
+
+var upDirection = vec3{0, 1, 0}
+
+// camera is the state driven by the mouse and keyboard controls, including
+// the sliders. It is what bookmarks store.
+//
+// Direction, U (right) and Up always form the basis Orientation rotates the
+// camera's local axes into. Pitch is only used by the standard camera, which
+// keeps U horizontal. Fov is the vertical field of view in radians.
+type camera struct {
+	Position      vec3       `json:"position"`
+	PositionFixed vec3       `json:"positionFixed"`
+	Direction     vec3       `json:"direction"`
+	Pitch         float64    `json:"pitch"`
+	U             vec3       `json:"u"`
+	Up            vec3       `json:"up"`
+	Orientation   quat       `json:"orientation"`
+	Fov           float32    `json:"fov"`
+	Speed         float32    `json:"speed"`
+	Sliders       [4]float32 `json:"sliders"`
+}
+
+func newCamera(fov float32) camera {
+	return camera{
+		Direction:   vec3{0, 0, 1},
+		Pitch:       startCameraPitch,
+		U:           vec3{1, 0, 0},
+		Up:          upDirection,
+		Orientation: identityQuat,
+		Fov:         fov,
+		Speed:       1,
+	}
+}
+
+// UnmarshalJSON fills in the orientation of cameras saved before it was
+// stored, which had no roll, and the field of view of cameras saved before
+// that was.
+func (c *camera) UnmarshalJSON(data []byte) error {
+	type fields camera
+	if err := json.Unmarshal(data, (*fields)(c)); err != nil {
+		return err
+	}
+	if c.Orientation == (quat{}) {
+		c.Up = c.Direction.cross(c.U)
+		c.Orientation = quatFromBasis(c.U, c.Up, c.Direction)
+	}
+	if c.Fov == 0 {
+		c.Fov = defaultFov
+	}
+	return nil
+}
+
+// frameState returns the state of a frame seen through c, for the caller to
+// fill in everything that is not part of the camera.
+func (c camera) frameState() frameState {
+	return frameState{
+		speed:         math32.Exp(c.Speed - 1),
+		position:      c.Position,
+		positionFixed: c.PositionFixed,
+		direction:     c.Direction,
+		right:         c.U,
+		up:            c.Up,
+		fov:           c.Fov,
+		sliders:       c.Sliders,
+	}
+}
+
+// rotate turns the camera by the mouse look deltas, keeping the pitch away
+// from the poles.
+func (c *camera) rotate(pitchDelta, yawDelta float64) {
+	clampedPitch := math.Max(0.001, math.Min(math.Pi-0.001, c.Pitch+pitchDelta))
+	c.Direction = c.Direction.rotateAroundAxis(c.U, float32(clampedPitch-c.Pitch)).rotateAroundAxis(upDirection, float32(yawDelta))
+	c.U = upDirection.cross(c.Direction).normalize()
+	c.Up = c.Direction.cross(c.U)
+	c.Orientation = quatFromBasis(c.U, c.Up, c.Direction)
+	c.Pitch = clampedPitch
+}
+
+// rotateFree turns the free-flight camera around its own axes, without any
+// notion of a world up direction.
+func (c *camera) rotateFree(pitchDelta, yawDelta, rollDelta float64) {
+	c.orient(c.Orientation.
+		mul(quatFromAxisAngle(vec3{0, 1, 0}, float32(yawDelta))).
+		mul(quatFromAxisAngle(vec3{1, 0, 0}, float32(pitchDelta))).
+		mul(quatFromAxisAngle(vec3{0, 0, 1}, float32(rollDelta))))
+}
+
+// orient points the camera with the orientation q.
+func (c *camera) orient(q quat) {
+	c.Orientation = q.normalize()
+	c.Direction = c.Orientation.rotate(vec3{0, 0, 1})
+	c.U = c.Orientation.rotate(vec3{1, 0, 0})
+	c.Up = c.Orientation.rotate(vec3{0, 1, 0})
+	c.Pitch = math.Acos(math.Max(-1, math.Min(1, float64(c.Direction.y))))
+}
+
+// zoom narrows the field of view by steps, each of which is a fixed ratio so
+// that zooming feels the same at any field of view.
+func (c *camera) zoom(steps float64) {
+	c.Fov = float32(math.Max(minFov, math.Min(maxFov, float64(c.Fov)*math.Exp(-0.1*steps))))
+}
+
+// level removes the roll of the camera, as the standard camera has none, and
+// moves the pitch back into the range it is clamped to.
+func (c *camera) level() {
+	c.Pitch = math.Max(0.001, math.Min(math.Pi-0.001, c.Pitch))
+	right := upDirection.cross(c.Direction)
+	if right.l2() < 1e-5 {
+		// Looking straight up or down, the right vector keeps the heading
+		right = vec3{c.U.x, 0, c.U.z}
+		if right.l2() < 1e-5 {
+			right = vec3{1, 0, 0}
+		}
+	}
+	c.U = right.normalize()
+	forward := c.U.cross(upDirection)
+	c.Direction = forward.scale(math32.Sin(float32(c.Pitch))).add(upDirection.scale(math32.Cos(float32(c.Pitch))))
+	c.Up = c.Direction.cross(c.U)
+	c.Orientation = quatFromBasis(c.U, c.Up, c.Direction)
+}
+
+// cameraTransition smoothly moves the camera between two states.
+type cameraTransition struct {
+	from, to camera
+	start    time.Time
+	duration time.Duration
+}
+
+// at returns the camera state at now, and whether the transition is over.
+func (t cameraTransition) at(now time.Time) (camera, bool) {
+	progress := float32(now.Sub(t.start)) / float32(t.duration)
+	if t.duration <= 0 || progress >= 1 {
+		return t.to, true
+	}
+	// Ease in and out
+	progress = progress * progress * (3 - 2*progress)
+
+	c := camera{
+		Position:      t.from.Position.lerp(t.to.Position, progress),
+		PositionFixed: t.from.PositionFixed.lerp(t.to.PositionFixed, progress),
+		Speed:         t.from.Speed + (t.to.Speed-t.from.Speed)*progress,
+		Fov:           t.from.Fov + (t.to.Fov-t.from.Fov)*progress,
+	}
+	for i := range c.Sliders {
+		c.Sliders[i] = t.from.Sliders[i] + (t.to.Sliders[i]-t.from.Sliders[i])*progress
+	}
+	c.orient(t.from.Orientation.slerp(t.to.Orientation, progress))
+	return c, false
+}
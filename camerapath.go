@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cameraKeyframe is a camera state at a time in seconds from the start of a
+// camera path.
+type cameraKeyframe struct {
+	Time   float64 `json:"time"`
+	Camera camera  `json:"camera"`
+}
+
+// cameraPath is a fly-through recorded as keyframes, in increasing time order.
+type cameraPath struct {
+	path      string
+	keyframes []cameraKeyframe
+}
+
+func cameraPathFile(shaderPath string) string {
+	return strings.TrimSuffix(shaderPath, filepath.Ext(shaderPath)) + ".path.json"
+}
+
+// loadCameraPath reads the camera path file at path. A missing file is only
+// an error if required is set, otherwise it holds an empty path.
+func loadCameraPath(path string, required bool) (*cameraPath, error) {
+	p := &cameraPath{path: path}
+	data, err := os.ReadFile(path)
+	if !required && errors.Is(err, fs.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read camera path file: %w", err)
+	}
+	if err := json.Unmarshal(data, &p.keyframes); err != nil {
+		return nil, fmt.Errorf("failed to parse camera path file %s: %w", path, err)
+	}
+	if required && len(p.keyframes) == 0 {
+		return nil, fmt.Errorf("error: Camera path %s has no keyframes", path)
+	}
+	for i := 1; i < len(p.keyframes); i++ {
+		if p.keyframes[i].Time <= p.keyframes[i-1].Time {
+			return nil, fmt.Errorf("error: Camera path keyframe times in %s must be increasing", path)
+		}
+	}
+	return p, nil
+}
+
+func (p *cameraPath) save() error {
+	data, err := json.MarshalIndent(p.keyframes, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode camera path: %w", err)
+	}
+	if err := os.WriteFile(p.path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write camera path file: %w", err)
+	}
+	return nil
+}
+
+// duration is the time of the last keyframe.
+func (p *cameraPath) duration() float64 {
+	if len(p.keyframes) == 0 {
+		return 0
+	}
+	return p.keyframes[len(p.keyframes)-1].Time
+}
+
+// at returns the camera at time t along the path. Positions follow a
+// Catmull-Rom spline through the keyframes, orientations are interpolated
+// spherically and everything else linearly. Times outside the path hold the
+// first or last keyframe. The path must have at least one keyframe.
+func (p *cameraPath) at(t float64) camera {
+	keyframes := p.keyframes
+	if t <= keyframes[0].Time {
+		return keyframes[0].Camera
+	}
+	if t >= p.duration() {
+		return keyframes[len(keyframes)-1].Camera
+	}
+
+	i := 1
+	for keyframes[i].Time < t {
+		i++
+	}
+	// The segment runs from k1 to k2, k0 and k3 only shape its tangents
+	k0, k1, k2, k3 := keyframes[max(0, i-2)], keyframes[i-1], keyframes[i], keyframes[min(len(keyframes)-1, i+1)]
+	progress := float32((t - k1.Time) / (k2.Time - k1.Time))
+
+	from, to := k1.Camera, k2.Camera
+	c := camera{
+		Position: catmullRom(k0.Camera.Position, from.Position, to.Position, k3.Camera.Position,
+			k0.Time, k1.Time, k2.Time, k3.Time, progress),
+		PositionFixed: catmullRom(k0.Camera.PositionFixed, from.PositionFixed, to.PositionFixed, k3.Camera.PositionFixed,
+			k0.Time, k1.Time, k2.Time, k3.Time, progress),
+		Speed: from.Speed + (to.Speed-from.Speed)*progress,
+		Fov:   from.Fov + (to.Fov-from.Fov)*progress,
+	}
+	for i := range c.Sliders {
+		c.Sliders[i] = from.Sliders[i] + (to.Sliders[i]-from.Sliders[i])*progress
+	}
+	c.orient(from.Orientation.slerp(to.Orientation, progress))
+	return c
+}
+
+// catmullRom evaluates the spline segment between p1 and p2 at progress. The
+// tangents take the keyframe times into account, so that unevenly spaced
+// keyframes do not make the camera overshoot. Duplicated end points give
+// one-sided tangents at the ends of the path.
+func catmullRom(p0, p1, p2, p3 vec3, t0, t1, t2, t3 float64, progress float32) vec3 {
+	segment := float32(t2 - t1)
+	tangent := func(a, b vec3, ta, tb float64) vec3 {
+		return b.add(a.scale(-1)).scale(segment / float32(tb-ta))
+	}
+	m1 := tangent(p0, p2, t0, t2)
+	m2 := tangent(p1, p3, t1, t3)
+
+	// Cubic Hermite basis
+	s, s2, s3 := progress, progress*progress, progress*progress*progress
+	return p1.scale(2*s3 - 3*s2 + 1).
+		add(m1.scale(s3 - 2*s2 + s)).
+		add(p2.scale(-2*s3 + 3*s2)).
+		add(m2.scale(s3 - s2))
+}
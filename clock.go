@@ -0,0 +1,79 @@
+package main
+
+import (
+	"math"
+)
+
+const (
+	// clockFrameStep is how far stepping moves the clock, a frame at 60 frames
+	// per second
+	clockFrameStep = 1. / 60
+	clockRateStep  = 0.25
+)
+
+// clock is the time shaders see. It follows the wall clock at a rate that can
+// be changed, including to negative rates which play animations backwards,
+// and can be paused, stepped and rewound.
+type clock struct {
+	start  float64
+	time   float64
+	rate   float64
+	paused bool
+	// loop is the period the clock wraps around after, 0 not to loop
+	loop float64
+}
+
+func newClock(start, loop float64) *clock {
+	return &clock{start: start, time: wrapTime(start, loop), rate: 1, loop: loop}
+}
+
+// advance moves the clock forward by elapsed seconds of wall clock time and
+// returns how much it moved.
+func (c *clock) advance(elapsed float64) float64 {
+	if c.paused {
+		return 0
+	}
+	return c.set(c.time + elapsed*c.rate)
+}
+
+// set moves the clock to t and returns how much it moved.
+func (c *clock) set(t float64) float64 {
+	previous := c.time
+	c.time = wrapTime(t, c.loop)
+	return t - previous
+}
+
+func (c *clock) togglePause() {
+	c.paused = !c.paused
+}
+
+// step pauses the clock and moves it by frames frames.
+func (c *clock) step(frames int) float64 {
+	c.paused = true
+	return c.set(c.time + float64(frames)*clockFrameStep)
+}
+
+// rewind moves the clock back to its start time.
+func (c *clock) rewind() float64 {
+	return c.set(c.start)
+}
+
+func (c *clock) changeRate(steps int) {
+	c.rate += float64(steps) * clockRateStep
+}
+
+func (c *clock) resetRate() {
+	c.rate = 1
+}
+
+// wrapTime wraps t into [0, loop) if loop is positive.
+func wrapTime(t, loop float64) float64 {
+	if loop <= 0 {
+		return t
+	}
+	t = math.Mod(t, loop)
+	if t < 0 {
+		t += loop
+	}
+	return t
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Compile log formats of the common drivers
+var (
+	// Mesa: 0:12(3): error: message
+	mesaLogPattern = regexp.MustCompile(`^(\d+):(\d+)\((\d+)\): (error|warning): (.*)$`)
+	// NVIDIA: 0(12) : error C0000: message
+	nvidiaLogPattern = regexp.MustCompile(`^(\d+)\((\d+)\) : (error|warning) (.*)$`)
+	// AMD: ERROR: 0:12: message
+	amdLogPattern = regexp.MustCompile(`^(ERROR|WARNING): (\d+):(\d+): (.*)$`)
+)
+
+// ANSI escape sequences used when printing to a terminal
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[1;31m"
+	ansiYellow = "\x1b[1;33m"
+	ansiGreen  = "\x1b[1;32m"
+)
+
+// shaderDiagnostic is a message of a compile log. Messages the log format is
+// not recognized for only have a message. Lines and columns start at 1, a
+// column of 0 is unknown.
+type shaderDiagnostic struct {
+	file     string
+	line     int
+	column   int
+	severity string
+	message  string
+	// source is the line of source the message is about
+	source string
+}
+
+// shaderError is a shader that failed to compile or link. Its diagnostics
+// are only parsed from the log for user shaders, whose sources are known.
+type shaderError struct {
+	stage string
+	log   string
+	// note explains the error, such as the shader having been compiled with
+	// an older GLSL version than it declares
+	note        string
+	diagnostics []shaderDiagnostic
+}
+
+func (e *shaderError) Error() string {
+	return e.format(false)
+}
+
+// format describes the error, printing the source line of each diagnostic
+// with a caret under the column, in color if color is set.
+func (e *shaderError) format(color bool) string {
+	paint := func(style, text string) string {
+		if !color {
+			return text
+		}
+		return style + text + ansiReset
+	}
+
+	var b strings.Builder
+	if e.note != "" {
+		b.WriteString(e.note + "\n")
+	}
+	fmt.Fprintf(&b, "[%s ERROR]:", e.stage)
+	if e.diagnostics == nil {
+		b.WriteString("\n" + e.log)
+		return b.String()
+	}
+	for _, d := range e.diagnostics {
+		b.WriteString("\n")
+		if d.file == "" {
+			b.WriteString(d.message)
+			continue
+		}
+		location := d.file + ":" + strconv.Itoa(d.line)
+		if d.column > 0 {
+			location += ":" + strconv.Itoa(d.column)
+		}
+		severityStyle := ansiRed
+		if d.severity == "warning" {
+			severityStyle = ansiYellow
+		}
+		fmt.Fprintf(&b, "%s: %s %s", paint(ansiBold, location), paint(severityStyle, d.severity+":"), d.message)
+		if d.source == "" {
+			continue
+		}
+		gutter := strconv.Itoa(d.line)
+		fmt.Fprintf(&b, "\n %s | %s", gutter, d.source)
+		if d.column > 0 {
+			// Tabs are kept so that the caret lines up with the source
+			source := []rune(d.source)
+			padding := source[:min(d.column-1, len(source))]
+			for i, r := range padding {
+				if r != '\t' {
+					padding[i] = ' '
+				}
+			}
+			fmt.Fprintf(&b, "\n %s | %s%s", strings.Repeat(" ", len(gutter)), string(padding), paint(ansiGreen, "^"))
+		}
+	}
+	return b.String()
+}
+
+// parseShaderLog splits a Mesa, NVIDIA or AMD compile log into diagnostics,
+// resolving the source string numbers of #line directives to the files of
+// source. Code gigashad generates around the shader is labeled <generated>.
+func parseShaderLog(log string, source shaderSource) []shaderDiagnostic {
+	diagnostics := []shaderDiagnostic{}
+	for _, line := range strings.Split(log, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var number, lineNumber, column, severity, message string
+		if match := mesaLogPattern.FindStringSubmatch(line); match != nil {
+			number, lineNumber, column, severity, message = match[1], match[2], match[3], match[4], match[5]
+		} else if match := nvidiaLogPattern.FindStringSubmatch(line); match != nil {
+			number, lineNumber, severity, message = match[1], match[2], match[3], match[4]
+		} else if match := amdLogPattern.FindStringSubmatch(line); match != nil {
+			number, lineNumber, severity, message = match[2], match[3], strings.ToLower(match[1]), match[4]
+		} else {
+			diagnostics = append(diagnostics, shaderDiagnostic{message: line})
+			continue
+		}
+
+		d := shaderDiagnostic{severity: severity, message: message}
+		index, _ := strconv.Atoi(number)
+		d.line, _ = strconv.Atoi(lineNumber)
+		d.column, _ = strconv.Atoi(column)
+		if index == generatedSourceNumber {
+			d.file = "<generated>"
+			diagnostics = append(diagnostics, d)
+			continue
+		}
+		if index >= len(source.files) {
+			diagnostics = append(diagnostics, shaderDiagnostic{message: line})
+			continue
+		}
+		d.file = source.files[index]
+		if lines := strings.Split(source.texts[index], "\n"); d.line >= 1 && d.line <= len(lines) {
+			d.source = strings.TrimRight(lines[d.line-1], "\r")
+		}
+		diagnostics = append(diagnostics, d)
+	}
+	return diagnostics
+}
+
+// describeError returns the message of err for printing to out, colored if
+// it is a shader error and out is a terminal that does not opt out of colors.
+func describeError(err error, out *os.File) string {
+	var shaderErr *shaderError
+	if errors.As(err, &shaderErr) && useColor(out) {
+		return shaderErr.format(true)
+	}
+	return err.Error()
+}
+
+func useColor(out *os.File) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	info, err := out.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
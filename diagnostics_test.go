@@ -0,0 +1,161 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+var testShaderSource = shaderSource{
+	files: []string{"main.frag", "lib/noise.glsl"},
+	texts: []string{
+		"#version 460 core\r\nout vec4 color;\r\nvoid main() {\r\n\tcolor = vec4(foo);\r\n}\r\n",
+		"float noise(vec2 p) {\n  return bar;\n}\n",
+	},
+}
+
+func TestParseShaderLog(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+		want []shaderDiagnostic
+	}{
+		{
+			name: "Mesa",
+			log:  "0:4(15): error: `foo' undeclared\n",
+			want: []shaderDiagnostic{
+				{file: "main.frag", line: 4, column: 15, severity: "error", message: "`foo' undeclared", source: "\tcolor = vec4(foo);"},
+			},
+		},
+		{
+			name: "Mesa in an include",
+			log:  "1:2(10): warning: `bar' used uninitialized",
+			want: []shaderDiagnostic{
+				{file: "lib/noise.glsl", line: 2, column: 10, severity: "warning", message: "`bar' used uninitialized", source: "  return bar;"},
+			},
+		},
+		{
+			name: "NVIDIA",
+			log:  "1(2) : error C1008: undefined variable \"bar\"",
+			want: []shaderDiagnostic{
+				{file: "lib/noise.glsl", line: 2, severity: "error", message: "C1008: undefined variable \"bar\"", source: "  return bar;"},
+			},
+		},
+		{
+			name: "AMD",
+			log:  "ERROR: 0:4: 'foo' : undeclared identifier \r\n",
+			want: []shaderDiagnostic{
+				{file: "main.frag", line: 4, severity: "error", message: "'foo' : undeclared identifier ", source: "\tcolor = vec4(foo);"},
+			},
+		},
+		{
+			name: "generated code",
+			log:  "1000:3(2): error: no function with name 'mainImage'",
+			want: []shaderDiagnostic{
+				{file: "<generated>", line: 3, column: 2, severity: "error", message: "no function with name 'mainImage'"},
+			},
+		},
+		{
+			name: "unknown format",
+			log:  "Fragment shader failed to compile with the following errors:\n\nerror: linking failed",
+			want: []shaderDiagnostic{
+				{message: "Fragment shader failed to compile with the following errors:"},
+				{message: "error: linking failed"},
+			},
+		},
+		{
+			name: "unknown source string",
+			log:  "7:1(1): error: message",
+			want: []shaderDiagnostic{
+				{message: "7:1(1): error: message"},
+			},
+		},
+		{
+			name: "line past the end of the file",
+			log:  "0:40(1): error: message",
+			want: []shaderDiagnostic{
+				{file: "main.frag", line: 40, column: 1, severity: "error", message: "message"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := parseShaderLog(test.log, testShaderSource)
+			if !slices.Equal(got, test.want) {
+				t.Errorf("diagnostics = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestShaderErrorFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		err  shaderError
+		want []string
+	}{
+		{
+			name: "diagnostics",
+			err: shaderError{
+				stage:       "FRAGMENT",
+				diagnostics: parseShaderLog("0:4(15): error: `foo' undeclared\n1:2(10): warning: `bar' used uninitialized\nerror: linking failed", testShaderSource),
+			},
+			want: []string{
+				"[FRAGMENT ERROR]:",
+				"main.frag:4:15: error: `foo' undeclared",
+				" 4 | \tcolor = vec4(foo);",
+				"   | \t             ^",
+				"lib/noise.glsl:2:10: warning: `bar' used uninitialized",
+				" 2 |   return bar;",
+				"   |          ^",
+				"error: linking failed",
+			},
+		},
+		{
+			name: "no column",
+			err: shaderError{
+				stage:       "FRAGMENT",
+				diagnostics: parseShaderLog("ERROR: 1:2: 'bar' : undeclared identifier", testShaderSource),
+			},
+			want: []string{
+				"[FRAGMENT ERROR]:",
+				"lib/noise.glsl:2: error: 'bar' : undeclared identifier",
+				" 2 |   return bar;",
+			},
+		},
+		{
+			name: "column past the end of the line",
+			err: shaderError{
+				stage:       "FRAGMENT",
+				diagnostics: []shaderDiagnostic{{file: "main.frag", line: 10, column: 6, severity: "error", message: "syntax error", source: "}"}},
+			},
+			want: []string{
+				"[FRAGMENT ERROR]:",
+				"main.frag:10:6: error: syntax error",
+				" 10 | }",
+				"    |  ^",
+			},
+		},
+		{
+			name: "note and raw log",
+			err: shaderError{
+				stage: "PROGRAM",
+				log:   "error: linking failed\n",
+				note:  "shader requires GLSL 460 (OpenGL 4.6) but the context only supports OpenGL 4.5, compiling it as GLSL 450 failed:",
+			},
+			want: []string{
+				"shader requires GLSL 460 (OpenGL 4.6) but the context only supports OpenGL 4.5, compiling it as GLSL 450 failed:",
+				"[PROGRAM ERROR]:",
+				"error: linking failed",
+				"",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got, want := test.err.format(false), strings.Join(test.want, "\n"); got != want {
+				t.Errorf("format =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
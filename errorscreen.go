@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+const errorScreenFragmentShaderSource = `
+	#version 460 core
+	out vec4 fragColor;
+	void main() {
+		// Diagonal stripes tell a broken shader apart from a dark one
+		float stripe = step(0.5, fract((gl_FragCoord.x + gl_FragCoord.y) / 48.0));
+		fragColor = vec4(mix(vec3(0.35, 0.02, 0.02), vec3(0.45, 0.04, 0.04), stripe), 0.8);
+	}` + "\x00"
+
+// errorScreen shows why passes failed to build in the window, tinting the
+// last frame that rendered and listing the errors over it.
+type errorScreen struct {
+	program uint32
+}
+
+func newErrorScreen() (*errorScreen, error) {
+	program, err := buildShader(quadVertexShaderSource, errorScreenFragmentShaderSource)
+	if err != nil {
+		return nil, err
+	}
+	return &errorScreen{program: program}, nil
+}
+
+// draw covers the screen with the fullscreen quad in vao and lists the build
+// errors of passes over it at window resolution, if any pass failed to build.
+// It returns whether it drew anything.
+func (e *errorScreen) draw(passes []*renderPass, text *textRenderer, vao uint32, screenWidth, screenHeight int) bool {
+	var lines []string
+	for _, pass := range passes {
+		if pass.buildErr == nil {
+			continue
+		}
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, fmt.Sprintf("%s failed to build, showing its last working version:", pass.path))
+		lines = append(lines, strings.Split(pass.buildErr.Error(), "\n")...)
+	}
+	if len(lines) == 0 {
+		return false
+	}
+
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	gl.UseProgram(e.program)
+	gl.BindVertexArray(vao)
+	gl.DrawArrays(gl.TRIANGLE_STRIP, 0, 4)
+	gl.Disable(gl.BLEND)
+
+	scale := max(1, screenHeight/480)
+	margin := 12 * scale
+	columns := max(1, (screenWidth-2*margin)/((glyphWidth+1)*scale))
+	text.draw(wrapLines(lines, columns), margin, margin, scale, screenWidth, screenHeight, [4]float32{1, 0.85, 0.85, 1})
+	return true
+}
+
+// wrapLines breaks lines longer than columns characters, so that long
+// messages stay on screen. Tabs count as a single character, as they are
+// drawn as one.
+func wrapLines(lines []string, columns int) []string {
+	var wrapped []string
+	for _, line := range lines {
+		chars := []rune(line)
+		for len(chars) > columns {
+			wrapped = append(wrapped, string(chars[:columns]))
+			chars = chars[columns:]
+		}
+		wrapped = append(wrapped, string(chars))
+	}
+	return wrapped
+}
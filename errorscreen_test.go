@@ -0,0 +1,28 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestWrapLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		columns int
+		want    []string
+	}{
+		{"short", []string{"abc", ""}, 4, []string{"abc", ""}},
+		{"exact", []string{"abcd"}, 4, []string{"abcd"}},
+		{"long", []string{"abcdefghij"}, 4, []string{"abcd", "efgh", "ij"}},
+		{"tabs", []string{"\t\tabc"}, 3, []string{"\t\ta", "bc"}},
+		{"multibyte", []string{"`é' → ü"}, 3, []string{"`é'", " → ", "ü"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := wrapLines(test.lines, test.columns); !slices.Equal(got, test.want) {
+				t.Errorf("wrapLines = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
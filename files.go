@@ -1,8 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"maps"
+	"math"
 	"os"
+	"slices"
 )
 
 func exists(path string) (bool, error) {
@@ -10,10 +18,43 @@ func exists(path string) (bool, error) {
 	return err == nil, err
 }
 
-func loadShaderSource(path string) (string, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return "", fmt.Errorf("failed to read shader file: %w", err)
+// writePNG encodes img as a PNG file, storing text as tEXt chunks right after
+// the header. Keys are written in sorted order so output is reproducible. A
+// positive dpi is stored as the physical pixel density.
+func writePNG(path string, img image.Image, text map[string]string, dpi float64) error {
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, img); err != nil {
+		return fmt.Errorf("failed to encode image file: %w", err)
 	}
-	return string(data) + "\x00", nil
+
+	// The signature is 8 bytes and the IHDR chunk always 25
+	const headerLength = 8 + 25
+	data := encoded.Bytes()
+	var chunks bytes.Buffer
+	for _, key := range slices.Sorted(maps.Keys(text)) {
+		writePNGChunk(&chunks, "tEXt", []byte(key+"\x00"+text[key]))
+	}
+	if dpi > 0 {
+		// pHYs stores pixels per meter for both axes, followed by the unit
+		pixelsPerMeter := uint32(math.Round(dpi / 0.0254))
+		physical := binary.BigEndian.AppendUint32(nil, pixelsPerMeter)
+		physical = binary.BigEndian.AppendUint32(physical, pixelsPerMeter)
+		writePNGChunk(&chunks, "pHYs", append(physical, 1))
+	}
+	output := slices.Concat(data[:headerLength], chunks.Bytes(), data[headerLength:])
+
+	if err := os.WriteFile(path, output, 0o644); err != nil {
+		return fmt.Errorf("failed to write image file: %w", err)
+	}
+	return nil
+}
+
+func writePNGChunk(w *bytes.Buffer, chunkType string, data []byte) {
+	binary.Write(w, binary.BigEndian, uint32(len(data)))
+	w.WriteString(chunkType)
+	w.Write(data)
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(chunkType))
+	crc.Write(data)
+	binary.Write(w, binary.BigEndian, crc.Sum32())
 }
@@ -3,16 +3,76 @@ package main
 import (
 	"flag"
 	"fmt"
+	"math"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 )
 
 type flags struct {
-	frag     string
-	width    int
-	ar       float64
-	windowed bool
+	frag               string
+	width              int
+	ar                 float64
+	windowed           bool
+	shadertoy          bool
+	buffers            [len(bufferNames)]string
+	channels           []channelBinding
+	render             string
+	fps                float64
+	start              float64
+	duration           float64
+	headless           bool
+	screenshotDir      string
+	poster             string
+	posterWidth        int
+	tileSize           int
+	supersample        int
+	dpi                float64
+	bookmarkTransition float64
+	cameraPath         string
+	freeFlight         bool
+	fov                float32
+	acceleration       float64
+	damping            float64
+	bindings           string
+	deadzone           float32
+	axisSensitivity    map[string]float32
+	params             map[string]float32
+	hud                bool
+	startTime          float64
+	loop               float64
+	timings            string
+	includeDirs        []string
+	targetFps          float64
+	bench              string
+	benchFrames        int
+	benchBaseline      string
+	benchThreshold     float64
+}
+
+// channelBinding binds the output of a buffer pass to an iChannel sampler of
+// another pass (or of the same one, which then reads its previous frame), or
+// binds an image file to it.
+type channelBinding struct {
+	pass    string
+	channel int
+	// source is the name of the bound buffer, empty when an image is bound
+	source  string
+	image   string
+	options textureOptions
+}
+
+// stringList collects the values of a flag that can be given several times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
 }
 
 func NewFlags() (*flags, error) {
@@ -20,6 +80,41 @@ func NewFlags() (*flags, error) {
 	width := flag.Int("width", 320, "Render width in pixels (default 320)")
 	ar := flag.String("ar", "16:9", "Render aspect ratio in width:height format (default \"16:9\")")
 	windowed := flag.Bool("windowed", false, "If provided, the render will be displayed in windowed mode using the render width and height as the window size")
+	shadertoy := flag.Bool("shadertoy", false, "If provided, the fragment shader is treated as a Shadertoy shader defining mainImage. Shaders defining mainImage but no main are detected automatically")
+	var buffers, channels, params, includeDirs stringList
+	flag.Var(&buffers, "buffer", "Buffer pass in NAME=PATH format, where NAME is one of A, B, C or D. Buffers are rendered in order before the main shader. Can be repeated")
+	flag.Var(&channels, "channel", "Channel input in PASS:N=SOURCE format, binding SOURCE to iChannelN of PASS (\"image\" for the main shader, or a buffer name). SOURCE is either a buffer name or a PNG/JPEG file optionally followed by comma separated options: filter=nearest|linear|mipmap, wrap=clamp|repeat|mirror and vflip=true|false (defaults: mipmap, repeat, true). Can be repeated")
+	flag.Var(&includeDirs, "include-dir", "Directory searched for files included with #include \"FILE\" or #include <FILE>, after the directory of the file including them. Can be repeated")
+	flag.Var(&params, "param", "Initial value of a shader parameter in NAME=VALUE format, overriding the default the shader declares with a \"// @param\" comment. Can be repeated")
+	render := flag.String("render", "", "If provided, renders frames offline into this directory as numbered PNG files and exits, without showing a window")
+	fps := flag.Float64("fps", 30, "Frames per second of offline renders (default 30)")
+	start := flag.Float64("start", 0, "Time in seconds of the first frame of offline renders, or of posters (default 0)")
+	duration := flag.Float64("duration", 0, "Duration in seconds of offline renders. A duration of 0 renders a single frame (default 0)")
+	headless := flag.Bool("headless", false, "If provided, renders through an EGL context without a window or display server, e.g. on servers using Mesa's software rasteriser. Requires -render, -poster or -bench, and a Linux build with the egl build tag")
+	screenshotDir := flag.String("screenshot-dir", ".", "Directory screenshots taken with F12 are saved to (default \".\")")
+	poster := flag.String("poster", "", "If provided, renders a single high resolution PNG to this path in tiles and exits, without showing a window")
+	posterWidth := flag.Int("poster-width", 7680, "Poster width in pixels, the height follows from the aspect ratio (default 7680)")
+	tileSize := flag.Int("tile", 1024, "Size in pixels of the tiles posters are rendered in, supersampling included (default 1024)")
+	supersample := flag.Int("supersample", 1, "Posters are rendered at this many times their resolution in each direction and downscaled (default 1)")
+	bench := flag.String("bench", "", "If provided, benchmarks the shader by rendering -bench-frames frames with the starting camera at -start, and writes their timing statistics to this JSON file and exits, without showing a window")
+	benchFrames := flag.Int("bench-frames", 300, "Number of frames benchmarks measure, after a few warm-up frames (default 300)")
+	benchBaseline := flag.String("bench-baseline", "", "JSON file written by an earlier benchmark of the same shader at the same resolution to compare against. Exits with status 3 if the average or 95th percentile frame or GPU time regressed by more than -bench-threshold, and 1 if the benchmark failed to run or the baseline is of another shader or resolution")
+	benchThreshold := flag.Float64("bench-threshold", 5, "Percentage by which benchmark timings can be slower than the baseline before counting as a regression (default 5)")
+	dpi := flag.Float64("dpi", 300, "Pixel density stored in posters, 0 to omit it (default 300)")
+	cameraPath := flag.String("camera-path", "", "JSON camera path file keyframes are recorded into with K and played back from with P. Offline renders follow the path when provided, starting from its first keyframe at -start (default: the shader path with a .path.json extension)")
+	fov := flag.Float64("fov", 60, "Initial vertical field of view in degrees, passed to shaders as iFov in radians. The scroll wheel zooms in and out (default 60)")
+	acceleration := flag.Float64("acceleration", 0, "Time in seconds the camera takes to get most of the way up to speed when moving, 0 to start moving instantly (default 0)")
+	damping := flag.Float64("damping", 0, "Time in seconds the camera takes to slow most of the way down when it stops moving, 0 to stop instantly (default 0)")
+	bindings := flag.String("bindings", "", "JSON file rebinding actions such as move-forward, speed-up or slider-x-inc to lists of keys such as \"w\", \"kp-add\" or \"ctrl+1\", or gamepad buttons such as \"gamepad-a\" or \"gamepad-dpad-up\". Actions it does not list keep their default keys. Keys are named by their physical position on a US keyboard, whatever the active layout")
+	deadzone := flag.Float64("deadzone", 0.15, "Fraction of the range of gamepad axes around their rest position that is ignored (default 0.15)")
+	axisSensitivity := flag.String("axis-sensitivity", "", "Comma separated gamepad axis sensitivities in NAME=VALUE format, where NAME is one of move-x, move-y, look-x, look-y, up, down, slider-z or slider-w. slider-z and slider-w follow the d-pad while A is held, or the axes after the sticks of joysticks without a gamepad mapping. Negative values invert an axis (default 1 for all axes)")
+	startTime := flag.Float64("start-time", 0, "Time in seconds the clock starts from and rewinds to with R in the window. Offline renders start from -start instead (default 0)")
+	loop := flag.Float64("loop", 0, "Period in seconds after which the time shaders see wraps back to 0, in the window and in offline renders. 0 does not loop (default 0)")
+	hud := flag.Bool("hud", false, "If provided, starts with the overlay showing the frame rate, frame timings, camera state and parameter values shown. F1 toggles it")
+	targetFps := flag.Float64("target-fps", 0, "If provided, the render resolution in the window goes down from -width when the GPU time of the shader passes exceeds the frame time of this frame rate, and back up when it fits again. Only GPU time is measured, so frames held back by the CPU or by vsync do not change the resolution. 0 keeps the resolution fixed (default 0)")
+	timings := flag.String("timings", "", "CSV file the CPU and GPU time of every frame rendered in the window is written to, in milliseconds")
+	freeFlight := flag.Bool("free-flight", false, "If provided, starts in free-flight camera mode, where the camera can roll with Z and C and pitch all the way around. F toggles between free-flight and the standard camera")
+	bookmarkTransition := flag.Float64("bookmark-transition", 0, "Duration in seconds of the camera transition when recalling a bookmark with 1 to 9, 0 to jump instantly (default 0)")
 
 	flag.Parse()
 
@@ -27,12 +122,8 @@ func NewFlags() (*flags, error) {
 		return nil, fmt.Errorf("error: Fragment shader source file not provided")
 	}
 
-	if fragExists, err := exists(*frag); *frag != "" && !fragExists {
-		return nil, fmt.Errorf("error: Fragment shader source file not found:\n\t%s", err.Error())
-	}
-
-	if *frag != "" && filepath.Ext(*frag) != ".frag" {
-		return nil, fmt.Errorf("error: Fragment shader source file must have a .frag extension")
+	if err := checkFragPath(*frag); err != nil {
+		return nil, err
 	}
 
 	if *width <= 0 {
@@ -44,14 +135,322 @@ func NewFlags() (*flags, error) {
 		return nil, fmt.Errorf("error: Aspect Ratio could not be parsed:\n\t%s", err.Error())
 	}
 
+	if *fps <= 0 {
+		return nil, fmt.Errorf("error: Frames per second must be greater than 0")
+	}
+
+	if *duration < 0 {
+		return nil, fmt.Errorf("error: Duration cannot be negative")
+	}
+
+	if *headless && !headlessSupported {
+		return nil, fmt.Errorf("error: Headless mode is only supported on Linux, in builds with the egl build tag (go build -tags egl)")
+	}
+
+	if *headless && *render == "" && *poster == "" && *bench == "" {
+		return nil, fmt.Errorf("error: Headless mode requires an offline render output directory (-render), a poster path (-poster) or a benchmark path (-bench)")
+	}
+
+	if *render != "" && *poster != "" {
+		return nil, fmt.Errorf("error: Offline renders and posters cannot be rendered at the same time")
+	}
+
+	if *bench != "" && (*render != "" || *poster != "") {
+		return nil, fmt.Errorf("error: Benchmarks cannot run at the same time as offline renders or posters")
+	}
+
+	if *bench != "" && filepath.Ext(*bench) != ".json" {
+		return nil, fmt.Errorf("error: Benchmark file must have a .json extension")
+	}
+
+	if *benchFrames <= 0 {
+		return nil, fmt.Errorf("error: Benchmark frames must be greater than 0")
+	}
+
+	if *benchBaseline != "" && *bench == "" {
+		return nil, fmt.Errorf("error: A benchmark baseline requires a benchmark path (-bench)")
+	}
+
+	if *benchThreshold < 0 {
+		return nil, fmt.Errorf("error: Benchmark threshold cannot be negative")
+	}
+
+	if *poster != "" && filepath.Ext(*poster) != ".png" {
+		return nil, fmt.Errorf("error: Poster file must have a .png extension")
+	}
+
+	if *posterWidth <= 0 {
+		return nil, fmt.Errorf("error: Poster width must be greater than 0")
+	}
+
+	if *supersample < 1 {
+		return nil, fmt.Errorf("error: Supersampling factor must be at least 1")
+	}
+
+	if *tileSize < *supersample {
+		return nil, fmt.Errorf("error: Tile size must be at least the supersampling factor")
+	}
+
+	if *dpi < 0 {
+		return nil, fmt.Errorf("error: DPI cannot be negative")
+	}
+
+	if *cameraPath != "" && filepath.Ext(*cameraPath) != ".json" {
+		return nil, fmt.Errorf("error: Camera path file must have a .json extension")
+	}
+
+	if *fov < 1 || *fov > 170 {
+		return nil, fmt.Errorf("error: Field of view must be between 1 and 170 degrees")
+	}
+
+	if *acceleration < 0 || *damping < 0 {
+		return nil, fmt.Errorf("error: Acceleration and damping times cannot be negative")
+	}
+
+	if *deadzone < 0 || *deadzone >= 1 {
+		return nil, fmt.Errorf("error: Dead-zone must be at least 0 and less than 1")
+	}
+
+	parsedAxisSensitivity, err := parseAxisSensitivity(*axisSensitivity)
+	if err != nil {
+		return nil, fmt.Errorf("error: Axis sensitivity could not be parsed:\n\t%s", err.Error())
+	}
+
+	parsedParams := map[string]float32{}
+	for _, param := range params {
+		name, value, err := parseParam(param)
+		if err != nil {
+			return nil, fmt.Errorf("error: Parameter could not be parsed:\n\t%s", err.Error())
+		}
+		parsedParams[name] = value
+	}
+
+	if *timings != "" && filepath.Ext(*timings) != ".csv" {
+		return nil, fmt.Errorf("error: Timings file must have a .csv extension")
+	}
+
+	if *targetFps < 0 {
+		return nil, fmt.Errorf("error: Target frames per second cannot be negative")
+	}
+
+	if *loop < 0 {
+		return nil, fmt.Errorf("error: Loop period cannot be negative")
+	}
+
+	if *bookmarkTransition < 0 {
+		return nil, fmt.Errorf("error: Bookmark transition duration cannot be negative")
+	}
+
+	parsedBuffers := [len(bufferNames)]string{}
+	for _, buffer := range buffers {
+		index, path, err := parseBuffer(buffer)
+		if err != nil {
+			return nil, fmt.Errorf("error: Buffer could not be parsed:\n\t%s", err.Error())
+		}
+		if err := checkFragPath(path); err != nil {
+			return nil, err
+		}
+		parsedBuffers[index] = path
+	}
+
+	if *poster != "" && parsedBuffers != [len(bufferNames)]string{} {
+		return nil, fmt.Errorf("error: Posters cannot be rendered with buffer passes")
+	}
+
+	parsedChannels := make([]channelBinding, 0, len(channels))
+	for _, channel := range channels {
+		binding, err := parseChannel(channel, parsedBuffers)
+		if err != nil {
+			return nil, fmt.Errorf("error: Channel could not be parsed:\n\t%s", err.Error())
+		}
+		parsedChannels = append(parsedChannels, binding)
+	}
+
 	return &flags{
-		frag:     *frag,
-		width:    *width,
-		ar:       parsedAspectRatio,
-		windowed: *windowed,
+		frag:               *frag,
+		width:              *width,
+		ar:                 parsedAspectRatio,
+		windowed:           *windowed,
+		shadertoy:          *shadertoy,
+		buffers:            parsedBuffers,
+		channels:           parsedChannels,
+		render:             *render,
+		fps:                *fps,
+		start:              *start,
+		duration:           *duration,
+		headless:           *headless,
+		screenshotDir:      *screenshotDir,
+		poster:             *poster,
+		posterWidth:        *posterWidth,
+		tileSize:           *tileSize,
+		supersample:        *supersample,
+		dpi:                *dpi,
+		bookmarkTransition: *bookmarkTransition,
+		cameraPath:         *cameraPath,
+		freeFlight:         *freeFlight,
+		fov:                float32(*fov * math.Pi / 180),
+		acceleration:       *acceleration,
+		damping:            *damping,
+		bindings:           *bindings,
+		deadzone:           float32(*deadzone),
+		axisSensitivity:    parsedAxisSensitivity,
+		params:             parsedParams,
+		hud:                *hud,
+		startTime:          *startTime,
+		loop:               *loop,
+		timings:            *timings,
+		includeDirs:        includeDirs,
+		targetFps:          *targetFps,
+		bench:              *bench,
+		benchFrames:        *benchFrames,
+		benchBaseline:      *benchBaseline,
+		benchThreshold:     *benchThreshold,
 	}, nil
 }
 
+func checkFragPath(path string) error {
+	if fragExists, err := exists(path); !fragExists {
+		return fmt.Errorf("error: Fragment shader source file not found:\n\t%s", err.Error())
+	}
+
+	if filepath.Ext(path) != ".frag" {
+		return fmt.Errorf("error: Fragment shader source file must have a .frag extension")
+	}
+
+	return nil
+}
+
+func parseBuffer(buffer string) (int, string, error) {
+	name, path, found := strings.Cut(buffer, "=")
+	if !found || path == "" {
+		return 0, "", fmt.Errorf("error: Invalid format, expected \"NAME=PATH\"")
+	}
+	index := bufferIndex(name)
+	if index < 0 {
+		return 0, "", fmt.Errorf("error: Invalid buffer name %q, expected one of %s", name, strings.Join(bufferNames[:], ", "))
+	}
+	return index, path, nil
+}
+
+func parseChannel(channel string, buffers [len(bufferNames)]string) (channelBinding, error) {
+	target, source, found := strings.Cut(channel, "=")
+	if !found {
+		return channelBinding{}, fmt.Errorf("error: Invalid format, expected \"PASS:N=SOURCE\"")
+	}
+	pass, number, found := strings.Cut(target, ":")
+	if !found {
+		return channelBinding{}, fmt.Errorf("error: Invalid format, expected \"PASS:N=SOURCE\"")
+	}
+
+	if pass != imagePassName && (bufferIndex(pass) < 0 || buffers[bufferIndex(pass)] == "") {
+		return channelBinding{}, fmt.Errorf("error: Unknown pass %q", pass)
+	}
+
+	index, err := strconv.Atoi(number)
+	if err != nil || index < 0 || index >= channelCount {
+		return channelBinding{}, fmt.Errorf("error: Invalid channel %q, expected 0 to %d", number, channelCount-1)
+	}
+
+	if bufferIndex(source) >= 0 {
+		if buffers[bufferIndex(source)] == "" {
+			return channelBinding{}, fmt.Errorf("error: Unknown source buffer %q", source)
+		}
+		return channelBinding{pass: pass, channel: index, source: source}, nil
+	}
+
+	path, options, _ := strings.Cut(source, ",")
+	if imageExists, err := exists(path); !imageExists {
+		return channelBinding{}, fmt.Errorf("error: Image file not found:\n\t%s", err.Error())
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png", ".jpg", ".jpeg":
+	default:
+		return channelBinding{}, fmt.Errorf("error: Image file must have a .png, .jpg or .jpeg extension")
+	}
+	parsedOptions, err := parseTextureOptions(options)
+	if err != nil {
+		return channelBinding{}, err
+	}
+
+	return channelBinding{pass: pass, channel: index, image: path, options: parsedOptions}, nil
+}
+
+func parseTextureOptions(options string) (textureOptions, error) {
+	parsed := defaultTextureOptions
+	if options == "" {
+		return parsed, nil
+	}
+	for _, option := range strings.Split(options, ",") {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "filter":
+			filter, ok := textureFilters[value]
+			if !ok {
+				return parsed, fmt.Errorf("error: Invalid filter %q, expected nearest, linear or mipmap", value)
+			}
+			parsed.filter = filter
+		case "wrap":
+			wrap, ok := textureWraps[value]
+			if !ok {
+				return parsed, fmt.Errorf("error: Invalid wrap mode %q, expected clamp, repeat or mirror", value)
+			}
+			parsed.wrap = wrap
+		case "vflip":
+			vflip, err := strconv.ParseBool(value)
+			if err != nil {
+				return parsed, fmt.Errorf("error: Invalid vflip value %q", value)
+			}
+			parsed.vflip = vflip
+		default:
+			return parsed, fmt.Errorf("error: Unknown texture option %q", key)
+		}
+	}
+	return parsed, nil
+}
+
+func parseParam(param string) (string, float32, error) {
+	name, value, found := strings.Cut(param, "=")
+	if !found || name == "" {
+		return "", 0, fmt.Errorf("error: Invalid format, expected \"NAME=VALUE\"")
+	}
+	parsed, err := strconv.ParseFloat(value, 32)
+	if err != nil {
+		return "", 0, fmt.Errorf("error: Invalid value %q", value)
+	}
+	return name, float32(parsed), nil
+}
+
+func parseAxisSensitivity(sensitivities string) (map[string]float32, error) {
+	parsed := map[string]float32{}
+	if sensitivities == "" {
+		return parsed, nil
+	}
+	for _, sensitivity := range strings.Split(sensitivities, ",") {
+		name, value, found := strings.Cut(sensitivity, "=")
+		if !found {
+			return nil, fmt.Errorf("error: Invalid format, expected \"NAME=VALUE\"")
+		}
+		if !slices.Contains(gamepadAxisNames, name) {
+			return nil, fmt.Errorf("error: Unknown axis %q, expected one of %s", name, strings.Join(gamepadAxisNames, ", "))
+		}
+		parsedValue, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return nil, fmt.Errorf("error: Invalid sensitivity value %q", value)
+		}
+		parsed[name] = float32(parsedValue)
+	}
+	return parsed, nil
+}
+
+func bufferIndex(name string) int {
+	for i, bufferName := range bufferNames {
+		if name == bufferName {
+			return i
+		}
+	}
+	return -1
+}
+
 func parseAspectRatio(ar string) (float64, error) {
 	operands := strings.Split(ar, ":")
 	if len(operands) != 2 {
@@ -89,3 +488,145 @@ func (f flags) Ar() float64 {
 func (f flags) Windowed() bool {
 	return f.windowed
 }
+
+func (f flags) Shadertoy() bool {
+	return f.shadertoy
+}
+
+func (f flags) Buffers() [len(bufferNames)]string {
+	return f.buffers
+}
+
+func (f flags) Channels() []channelBinding {
+	return f.channels
+}
+
+func (f flags) Render() string {
+	return f.render
+}
+
+func (f flags) Fps() float64 {
+	return f.fps
+}
+
+func (f flags) Start() float64 {
+	return f.start
+}
+
+func (f flags) Duration() float64 {
+	return f.duration
+}
+
+func (f flags) Headless() bool {
+	return f.headless
+}
+
+func (f flags) ScreenshotDir() string {
+	return f.screenshotDir
+}
+
+func (f flags) Poster() string {
+	return f.poster
+}
+
+func (f flags) PosterWidth() int {
+	return f.posterWidth
+}
+
+func (f flags) TileSize() int {
+	return f.tileSize
+}
+
+func (f flags) Supersample() int {
+	return f.supersample
+}
+
+func (f flags) Dpi() float64 {
+	return f.dpi
+}
+
+func (f flags) BookmarkTransition() float64 {
+	return f.bookmarkTransition
+}
+
+func (f flags) CameraPath() string {
+	return f.cameraPath
+}
+
+func (f flags) FreeFlight() bool {
+	return f.freeFlight
+}
+
+// Fov is the initial vertical field of view in radians.
+func (f flags) Fov() float32 {
+	return f.fov
+}
+
+func (f flags) Acceleration() float64 {
+	return f.acceleration
+}
+
+func (f flags) Damping() float64 {
+	return f.damping
+}
+
+func (f flags) Bindings() string {
+	return f.bindings
+}
+
+func (f flags) Deadzone() float32 {
+	return f.deadzone
+}
+
+func (f flags) AxisSensitivity() map[string]float32 {
+	return f.axisSensitivity
+}
+
+func (f flags) Params() map[string]float32 {
+	return f.params
+}
+
+func (f flags) Hud() bool {
+	return f.hud
+}
+
+func (f flags) StartTime() float64 {
+	return f.startTime
+}
+
+func (f flags) Loop() float64 {
+	return f.loop
+}
+
+func (f flags) Timings() string {
+	return f.timings
+}
+
+func (f flags) IncludeDirs() []string {
+	return f.includeDirs
+}
+
+func (f flags) TargetFps() float64 {
+	return f.targetFps
+}
+
+func (f flags) Bench() string {
+	return f.bench
+}
+
+func (f flags) BenchFrames() int {
+	return f.benchFrames
+}
+
+func (f flags) BenchBaseline() string {
+	return f.benchBaseline
+}
+
+func (f flags) BenchThreshold() float64 {
+	return f.benchThreshold
+}
+
+// Offline reports whether frames are rendered to files instead of a window.
+func (f flags) Offline() bool {
+	return f.render != "" || f.poster != "" || f.bench != ""
+}
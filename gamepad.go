@@ -0,0 +1,167 @@
+package main
+
+import (
+	"github.com/chewxy/math32"
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// gamepadAxisNames are the analog controls, whose sensitivity can be set.
+var gamepadAxisNames = []string{"move-x", "move-y", "look-x", "look-y", "up", "down", "slider-z", "slider-w"}
+
+var gamepadButtonNames = map[string]glfw.GamepadButton{
+	"gamepad-a":            glfw.ButtonA,
+	"gamepad-b":            glfw.ButtonB,
+	"gamepad-x":            glfw.ButtonX,
+	"gamepad-y":            glfw.ButtonY,
+	"gamepad-left-bumper":  glfw.ButtonLeftBumper,
+	"gamepad-right-bumper": glfw.ButtonRightBumper,
+	"gamepad-back":         glfw.ButtonBack,
+	"gamepad-start":        glfw.ButtonStart,
+	"gamepad-guide":        glfw.ButtonGuide,
+	"gamepad-left-thumb":   glfw.ButtonLeftThumb,
+	"gamepad-right-thumb":  glfw.ButtonRightThumb,
+	"gamepad-dpad-up":      glfw.ButtonDpadUp,
+	"gamepad-dpad-right":   glfw.ButtonDpadRight,
+	"gamepad-dpad-down":    glfw.ButtonDpadDown,
+	"gamepad-dpad-left":    glfw.ButtonDpadLeft,
+}
+
+// gamepadHatButtons are the d-pad buttons pressed by the directions of a
+// joystick hat.
+var gamepadHatButtons = [...]struct {
+	hat    glfw.JoystickHatState
+	button glfw.GamepadButton
+}{
+	{glfw.HatUp, glfw.ButtonDpadUp},
+	{glfw.HatRight, glfw.ButtonDpadRight},
+	{glfw.HatDown, glfw.ButtonDpadDown},
+	{glfw.HatLeft, glfw.ButtonDpadLeft},
+}
+
+// gamepad reads the first connected gamepad. Joysticks GLFW has no gamepad
+// mapping for are read as gamepads with two sticks on their first four axes,
+// their first buttons in the order of gamepad buttons and the d-pad on their
+// first hat, and their remaining axes drive the slider-z and slider-w
+// controls. On any of them, the d-pad drives slider-z and slider-w while A is
+// held, as standard gamepads have no axes left for them.
+type gamepad struct {
+	deadzone    float32
+	sensitivity map[string]float32
+	state       glfw.GamepadState
+	// sliders are the slider-z and slider-w axes
+	sliders [2]float32
+}
+
+func newGamepad(deadzone float32, sensitivity map[string]float32) *gamepad {
+	return &gamepad{deadzone: deadzone, sensitivity: sensitivity}
+}
+
+// poll reads the current state of the gamepad, which is at rest if none is
+// connected.
+func (g *gamepad) poll() {
+	g.state = glfw.GamepadState{}
+	g.state.Axes[glfw.AxisLeftTrigger], g.state.Axes[glfw.AxisRightTrigger] = -1, -1
+	g.sliders = [2]float32{}
+	for joystick := glfw.Joystick1; joystick <= glfw.JoystickLast; joystick++ {
+		if !joystick.Present() {
+			continue
+		}
+		if joystick.IsGamepad() {
+			if state := joystick.GetGamepadState(); state != nil {
+				g.state = *state
+			}
+		} else {
+			g.pollJoystick(joystick)
+		}
+		if sliders, ok := dpadSliders(&g.state); ok {
+			g.sliders = sliders
+		}
+		return
+	}
+}
+
+func (g *gamepad) pollJoystick(joystick glfw.Joystick) {
+	axes := joystick.GetAxes()
+	copy(g.state.Axes[:4], axes)
+	if len(axes) > 4 {
+		copy(g.sliders[:], axes[4:])
+	}
+	// The d-pad buttons come from the hat instead
+	buttons := joystick.GetButtons()
+	copy(g.state.Buttons[:glfw.ButtonDpadUp], buttons[:min(len(buttons), int(glfw.ButtonDpadUp))])
+	if hats := joystick.GetHats(); len(hats) > 0 {
+		for _, hat := range gamepadHatButtons {
+			if hats[0]&hat.hat != 0 {
+				g.state.Buttons[hat.button] = glfw.Press
+			}
+		}
+	}
+}
+
+// dpadSliders returns the slider-z and slider-w axes the d-pad drives while A
+// is held, releasing the d-pad buttons so that they do not also drive
+// slider-x and slider-y. It reports whether A is held.
+func dpadSliders(state *glfw.GamepadState) ([2]float32, bool) {
+	if state.Buttons[glfw.ButtonA] != glfw.Press {
+		return [2]float32{}, false
+	}
+	direction := func(negative, positive glfw.GamepadButton) float32 {
+		var value float32
+		if state.Buttons[negative] == glfw.Press {
+			value--
+		}
+		if state.Buttons[positive] == glfw.Press {
+			value++
+		}
+		return value
+	}
+	sliders := [2]float32{
+		direction(glfw.ButtonDpadLeft, glfw.ButtonDpadRight),
+		direction(glfw.ButtonDpadDown, glfw.ButtonDpadUp),
+	}
+	for _, hat := range gamepadHatButtons {
+		state.Buttons[hat.button] = glfw.Release
+	}
+	return sliders, true
+}
+
+func (g *gamepad) button(button glfw.GamepadButton) bool {
+	return g.state.Buttons[button] == glfw.Press
+}
+
+// axis returns the value of the analog control called name, between -1 and 1
+// (0 and 1 for the triggers driving up and down) times its sensitivity.
+// Values within the dead-zone are 0, and values outside of it are rescaled
+// to start from 0 at its edge.
+func (g *gamepad) axis(name string) float32 {
+	var value float32
+	switch name {
+	case "move-x":
+		value = g.state.Axes[glfw.AxisLeftX]
+	case "move-y":
+		// Stick Y axes point down
+		value = -g.state.Axes[glfw.AxisLeftY]
+	case "look-x":
+		value = g.state.Axes[glfw.AxisRightX]
+	case "look-y":
+		value = g.state.Axes[glfw.AxisRightY]
+	case "up":
+		value = (g.state.Axes[glfw.AxisRightTrigger] + 1) / 2
+	case "down":
+		value = (g.state.Axes[glfw.AxisLeftTrigger] + 1) / 2
+	case "slider-z":
+		value = g.sliders[0]
+	case "slider-w":
+		value = g.sliders[1]
+	}
+
+	magnitude := max(0, (math32.Abs(value)-g.deadzone)/(1-g.deadzone))
+	if value < 0 {
+		magnitude = -magnitude
+	}
+	sensitivity, ok := g.sensitivity[name]
+	if !ok {
+		sensitivity = 1
+	}
+	return magnitude * sensitivity
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+func TestDpadSliders(t *testing.T) {
+	tests := []struct {
+		name    string
+		pressed []glfw.GamepadButton
+		want    [2]float32
+		wantOk  bool
+	}{
+		{"d-pad alone", []glfw.GamepadButton{glfw.ButtonDpadLeft}, [2]float32{}, false},
+		{"A alone", []glfw.GamepadButton{glfw.ButtonA}, [2]float32{}, true},
+		{"A and left", []glfw.GamepadButton{glfw.ButtonA, glfw.ButtonDpadLeft}, [2]float32{-1, 0}, true},
+		{"A and up right", []glfw.GamepadButton{glfw.ButtonA, glfw.ButtonDpadUp, glfw.ButtonDpadRight}, [2]float32{1, 1}, true},
+		{"A and opposite directions", []glfw.GamepadButton{glfw.ButtonA, glfw.ButtonDpadUp, glfw.ButtonDpadDown}, [2]float32{0, 0}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var state glfw.GamepadState
+			for _, button := range test.pressed {
+				state.Buttons[button] = glfw.Press
+			}
+			sliders, ok := dpadSliders(&state)
+			if sliders != test.want || ok != test.wantOk {
+				t.Errorf("dpadSliders = %v, %t, want %v, %t", sliders, ok, test.want, test.wantOk)
+			}
+			// The d-pad only drives slider-x and slider-y while A is not held
+			dpadDown := state.Buttons[glfw.ButtonDpadLeft] == glfw.Press
+			if wantDown := !test.wantOk && slices.Contains(test.pressed, glfw.ButtonDpadLeft); dpadDown != wantDown {
+				t.Errorf("d-pad left pressed = %t, want %t", dpadDown, wantDown)
+			}
+		})
+	}
+}
+
+func TestGamepadSliderAxes(t *testing.T) {
+	g := newGamepad(0.5, map[string]float32{"slider-w": -2})
+	g.sliders = [2]float32{0.25, 0.75}
+	if got := g.axis("slider-z"); got != 0 {
+		t.Errorf("slider-z within the dead-zone = %g, want 0", got)
+	}
+	if got := g.axis("slider-w"); got != -1 {
+		t.Errorf("inverted slider-w = %g, want -1", got)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+type glVersion struct {
+	major, minor int
+}
+
+// contextVersions are the core profile versions tried when creating a
+// context, from most to least preferred.
+var contextVersions = []glVersion{{4, 6}, {4, 5}, {4, 4}, {4, 3}, {4, 2}, {4, 1}, {4, 0}, {3, 3}}
+
+func (v glVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.major, v.minor)
+}
+
+// glsl returns the GLSL version matching the OpenGL version, as written in
+// #version directives.
+func (v glVersion) glsl() int {
+	return v.major*100 + v.minor*10
+}
+
+// glVersionFromGLSL is the inverse of glVersion.glsl.
+func glVersionFromGLSL(glsl int) glVersion {
+	return glVersion{glsl / 100, glsl % 100 / 10}
+}
+
+// currentGLVersion returns the version of the current context.
+func currentGLVersion() glVersion {
+	var major, minor int32
+	gl.GetIntegerv(gl.MAJOR_VERSION, &major)
+	gl.GetIntegerv(gl.MINOR_VERSION, &minor)
+	return glVersion{int(major), int(minor)}
+}
+
+var versionDirectivePattern = regexp.MustCompile(`(?m)^([ \t]*)#version[ \t]+(\d+)([ \t]+(?:core|compatibility))?[ \t\r]*$`)
+
+// matchContextVersion lowers the #version directive of a desktop GLSL source
+// to the highest version the context supports, keeping the rest of the
+// directive, such as its profile. Sources declaring a version the context
+// supports are left untouched. It returns the originally declared version, or
+// 0 if the directive was not rewritten.
+func matchContextVersion(source string, context glVersion) (string, int) {
+	match := versionDirectivePattern.FindStringSubmatchIndex(source)
+	if match == nil {
+		return source, 0
+	}
+	declared, err := strconv.Atoi(source[match[4]:match[5]])
+	if err != nil || declared <= context.glsl() {
+		return source, 0
+	}
+	return source[:match[4]] + strconv.Itoa(context.glsl()) + source[match[5]:], declared
+}
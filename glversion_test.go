@@ -0,0 +1,70 @@
+package main
+
+import "testing"
+
+func TestMatchContextVersion(t *testing.T) {
+	context := glVersion{4, 5}
+	tests := []struct {
+		name     string
+		source   string
+		want     string
+		declared int
+	}{
+		{
+			name:   "declared version supported",
+			source: "#version 450 core\nvoid main() {}\n",
+			want:   "#version 450 core\nvoid main() {}\n",
+		},
+		{
+			name:   "older version supported",
+			source: "#version 330\nvoid main() {}\n",
+			want:   "#version 330\nvoid main() {}\n",
+		},
+		{
+			name:     "core profile",
+			source:   "#version 460 core\nvoid main() {}\n",
+			want:     "#version 450 core\nvoid main() {}\n",
+			declared: 460,
+		},
+		{
+			name:     "compatibility profile",
+			source:   "#version 460 compatibility\nvoid main() {}\n",
+			want:     "#version 450 compatibility\nvoid main() {}\n",
+			declared: 460,
+		},
+		{
+			name:     "no profile",
+			source:   "#version 460\nvoid main() {}\n",
+			want:     "#version 450\nvoid main() {}\n",
+			declared: 460,
+		},
+		{
+			name:     "leading whitespace",
+			source:   "\n  \t#version 460 core\nvoid main() {}\n",
+			want:     "\n  \t#version 450 core\nvoid main() {}\n",
+			declared: 460,
+		},
+		{
+			name:     "CRLF line endings",
+			source:   "#version 460 core\r\nvoid main() {}\r\n",
+			want:     "#version 450 core\r\nvoid main() {}\r\n",
+			declared: 460,
+		},
+		{
+			name:   "no directive",
+			source: "void main() {}\n",
+			want:   "void main() {}\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, declared := matchContextVersion(test.source, context)
+			if got != test.want {
+				t.Errorf("source = %q, want %q", got, test.want)
+			}
+			if declared != test.declared {
+				t.Errorf("declared = %d, want %d", declared, test.declared)
+			}
+		})
+	}
+}
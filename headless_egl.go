@@ -0,0 +1,152 @@
+//go:build linux && egl
+
+// The headless backend links against libEGL, so it is only built with the
+// egl build tag: go build -tags egl
+
+package main
+
+/*
+#cgo LDFLAGS: -lEGL
+#include <stdlib.h>
+#include <EGL/egl.h>
+#include <EGL/eglext.h>
+
+// getPlatformDisplay wraps eglGetPlatformDisplayEXT, which is only available
+// through eglGetProcAddress.
+static EGLDisplay getPlatformDisplay(EGLenum platform) {
+	PFNEGLGETPLATFORMDISPLAYEXTPROC getPlatformDisplayEXT =
+		(PFNEGLGETPLATFORMDISPLAYEXTPROC) eglGetProcAddress("eglGetPlatformDisplayEXT");
+	if (getPlatformDisplayEXT == NULL) {
+		return EGL_NO_DISPLAY;
+	}
+	return getPlatformDisplayEXT(platform, EGL_DEFAULT_DISPLAY, NULL);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"unsafe"
+)
+
+// headlessSupported reports whether this build has a headless backend.
+const headlessSupported = true
+
+// headlessContext is an OpenGL context created through EGL without any
+// window or display server. It prefers Mesa's surfaceless platform, which
+// also works with the llvmpipe software rasteriser on machines without a
+// GPU, and falls back to the default display with a pbuffer surface.
+type headlessContext struct {
+	display C.EGLDisplay
+	context C.EGLContext
+	surface C.EGLSurface
+}
+
+func newHeadlessContext() (*headlessContext, error) {
+	c := &headlessContext{display: C.EGLDisplay(C.EGL_NO_DISPLAY), surface: C.EGLSurface(C.EGL_NO_SURFACE)}
+
+	if hasEGLExtension(C.EGLDisplay(C.EGL_NO_DISPLAY), "EGL_MESA_platform_surfaceless") {
+		c.display = C.getPlatformDisplay(C.EGL_PLATFORM_SURFACELESS_MESA)
+	}
+	if c.display == C.EGLDisplay(C.EGL_NO_DISPLAY) {
+		c.display = C.eglGetDisplay(C.EGLNativeDisplayType(C.EGL_DEFAULT_DISPLAY))
+	}
+	if c.display == C.EGLDisplay(C.EGL_NO_DISPLAY) {
+		return nil, fmt.Errorf("failed to get an EGL display")
+	}
+
+	var major, minor C.EGLint
+	if C.eglInitialize(c.display, &major, &minor) == C.EGL_FALSE {
+		return nil, eglError("failed to initialize EGL")
+	}
+	if C.eglBindAPI(C.EGL_OPENGL_API) == C.EGL_FALSE {
+		c.destroy()
+		return nil, eglError("failed to bind the OpenGL API")
+	}
+
+	configAttributes := []C.EGLint{
+		C.EGL_SURFACE_TYPE, C.EGL_PBUFFER_BIT,
+		C.EGL_RENDERABLE_TYPE, C.EGL_OPENGL_BIT,
+		C.EGL_RED_SIZE, 8,
+		C.EGL_GREEN_SIZE, 8,
+		C.EGL_BLUE_SIZE, 8,
+		C.EGL_ALPHA_SIZE, 8,
+		C.EGL_NONE,
+	}
+	var config C.EGLConfig
+	var configCount C.EGLint
+	if C.eglChooseConfig(c.display, &configAttributes[0], &config, 1, &configCount) == C.EGL_FALSE || configCount == 0 {
+		c.destroy()
+		return nil, eglError("failed to choose an EGL config")
+	}
+
+	// Use the newest core profile the driver offers
+	c.context = C.EGLContext(C.EGL_NO_CONTEXT)
+	for _, version := range contextVersions {
+		contextAttributes := []C.EGLint{
+			C.EGL_CONTEXT_MAJOR_VERSION, C.EGLint(version.major),
+			C.EGL_CONTEXT_MINOR_VERSION, C.EGLint(version.minor),
+			C.EGL_CONTEXT_OPENGL_PROFILE_MASK, C.EGL_CONTEXT_OPENGL_CORE_PROFILE_BIT,
+			C.EGL_NONE,
+		}
+		c.context = C.eglCreateContext(c.display, config, C.EGLContext(C.EGL_NO_CONTEXT), &contextAttributes[0])
+		if c.context != C.EGLContext(C.EGL_NO_CONTEXT) {
+			break
+		}
+		log.Printf("OpenGL %s core context not available\n", version)
+	}
+	if c.context == C.EGLContext(C.EGL_NO_CONTEXT) {
+		c.destroy()
+		return nil, eglError("failed to create an OpenGL core context")
+	}
+
+	// Everything is rendered into framebuffer objects, so a surface is only
+	// needed when the driver cannot make a context current without one
+	if !hasEGLExtension(c.display, "EGL_KHR_surfaceless_context") {
+		surfaceAttributes := []C.EGLint{C.EGL_WIDTH, 1, C.EGL_HEIGHT, 1, C.EGL_NONE}
+		c.surface = C.eglCreatePbufferSurface(c.display, config, &surfaceAttributes[0])
+		if c.surface == C.EGLSurface(C.EGL_NO_SURFACE) {
+			c.destroy()
+			return nil, eglError("failed to create a pbuffer surface")
+		}
+	}
+
+	if C.eglMakeCurrent(c.display, c.surface, c.surface, c.context) == C.EGL_FALSE {
+		c.destroy()
+		return nil, eglError("failed to make the EGL context current")
+	}
+	return c, nil
+}
+
+func (c *headlessContext) destroy() {
+	C.eglMakeCurrent(c.display, C.EGLSurface(C.EGL_NO_SURFACE), C.EGLSurface(C.EGL_NO_SURFACE), C.EGLContext(C.EGL_NO_CONTEXT))
+	if c.surface != C.EGLSurface(C.EGL_NO_SURFACE) {
+		C.eglDestroySurface(c.display, c.surface)
+	}
+	if c.context != nil && c.context != C.EGLContext(C.EGL_NO_CONTEXT) {
+		C.eglDestroyContext(c.display, c.context)
+	}
+	C.eglTerminate(c.display)
+}
+
+// getProcAddress loads OpenGL functions for the headless context, to be
+// passed to gl.InitWithProcAddrFunc.
+func (c *headlessContext) getProcAddress(name string) unsafe.Pointer {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	return unsafe.Pointer(C.eglGetProcAddress(cname))
+}
+
+func hasEGLExtension(display C.EGLDisplay, extension string) bool {
+	extensions := C.eglQueryString(display, C.EGL_EXTENSIONS)
+	if extensions == nil {
+		return false
+	}
+	return strings.Contains(" "+C.GoString(extensions)+" ", " "+extension+" ")
+}
+
+func eglError(message string) error {
+	return fmt.Errorf("%s (EGL error 0x%04x)", message, int(C.eglGetError()))
+}
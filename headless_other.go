@@ -0,0 +1,25 @@
+//go:build !(linux && egl)
+
+package main
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// headlessSupported reports whether this build has a headless backend.
+const headlessSupported = false
+
+// headlessContext is only implemented on Linux, through EGL, in builds with
+// the egl build tag.
+type headlessContext struct{}
+
+func newHeadlessContext() (*headlessContext, error) {
+	return nil, fmt.Errorf("headless rendering is only supported on Linux, in builds with the egl build tag (go build -tags egl)")
+}
+
+func (c *headlessContext) destroy() {}
+
+func (c *headlessContext) getProcAddress(name string) unsafe.Pointer {
+	return nil
+}
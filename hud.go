@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/chewxy/math32"
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+const textVertexShaderSource = `
+	#version 460 core
+	layout(location = 0) in vec2 position;
+	layout(location = 1) in vec2 texel;
+	uniform vec2 screenSize;
+	out vec2 fontTexel;
+	void main() {
+		fontTexel = texel;
+		vec2 ndc = position / screenSize * 2.0 - 1.0;
+		gl_Position = vec4(ndc.x, -ndc.y, 0.0, 1.0);
+	}` + "\x00"
+
+const textFragmentShaderSource = `
+	#version 460 core
+	in vec2 fontTexel;
+	out vec4 fragColor;
+	uniform sampler2D font;
+	uniform vec4 color;
+	uniform bool solid;
+	void main() {
+		float coverage = solid ? 1.0 : texelFetch(font, ivec2(fontTexel), 0).r;
+		fragColor = vec4(color.rgb, color.a * coverage);
+	}` + "\x00"
+
+// textRenderer draws text with the embedded bitmap font straight into the
+// default framebuffer, at window resolution.
+type textRenderer struct {
+	program    uint32
+	font       uint32
+	vao, vbo   uint32
+	screenSize int32
+	color      int32
+	solid      int32
+}
+
+func newTextRenderer() (*textRenderer, error) {
+	program, err := buildShader(textVertexShaderSource, textFragmentShaderSource)
+	if err != nil {
+		return nil, err
+	}
+	t := &textRenderer{
+		program:    program,
+		screenSize: gl.GetUniformLocation(program, gl.Str("screenSize\x00")),
+		color:      gl.GetUniformLocation(program, gl.Str("color\x00")),
+		solid:      gl.GetUniformLocation(program, gl.Str("solid\x00")),
+	}
+
+	// The atlas texture keeps the layout of fontAtlas, its first row of
+	// texels being the top of the first row of glyphs
+	rows := strings.Split(strings.Trim(fontAtlas, "\n"), "\n")
+	width, height := len(rows[0]), len(rows)
+	pixels := make([]uint8, width*height)
+	for y, row := range rows {
+		for x := range row {
+			if row[x] == '#' {
+				pixels[y*width+x] = 255
+			}
+		}
+	}
+	gl.GenTextures(1, &t.font)
+	gl.BindTexture(gl.TEXTURE_2D, t.font)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.R8, int32(width), int32(height), 0, gl.RED, gl.UNSIGNED_BYTE, gl.Ptr(pixels))
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 4)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+
+	gl.GenVertexArrays(1, &t.vao)
+	gl.BindVertexArray(t.vao)
+	gl.GenBuffers(1, &t.vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, t.vbo)
+	gl.VertexAttribPointer(0, 2, gl.FLOAT, false, 4*4, nil)
+	gl.EnableVertexAttribArray(0)
+	gl.VertexAttribPointer(1, 2, gl.FLOAT, false, 4*4, gl.PtrOffset(2*4))
+	gl.EnableVertexAttribArray(1)
+	return t, nil
+}
+
+// appendQuad appends the two triangles of a rectangle in pixels to vertices,
+// textured with the rectangle of the atlas starting at texel (tx, ty).
+func appendQuad(vertices []float32, x, y, w, h, tx, ty, tw, th float32) []float32 {
+	return append(vertices,
+		x, y, tx, ty,
+		x+w, y, tx+tw, ty,
+		x, y+h, tx, ty+th,
+		x+w, y, tx+tw, ty,
+		x+w, y+h, tx+tw, ty+th,
+		x, y+h, tx, ty+th,
+	)
+}
+
+// draw draws lines of text over a translucent background, with the top left
+// corner of the text at (x, y) pixels from the top left of the screen.
+// Glyphs are scaled up by an integer scale to stay sharp.
+func (t *textRenderer) draw(lines []string, x, y, scale, screenWidth, screenHeight int, color [4]float32) {
+	advanceX, advanceY := float32((glyphWidth+1)*scale), float32((glyphHeight+2)*scale)
+	padding := float32(2 * scale)
+
+	columns := 0
+	for _, line := range lines {
+		columns = max(columns, utf8.RuneCountInString(line))
+	}
+	background := appendQuad(nil, float32(x)-padding, float32(y)-padding,
+		float32(columns)*advanceX+2*padding-float32(scale), float32(len(lines))*advanceY+2*padding-2*float32(scale), 0, 0, 0, 0)
+
+	var glyphs []float32
+	for row, line := range lines {
+		// Characters the font lacks leave a blank column
+		for column, char := range []rune(line) {
+			index := int(char - firstGlyph)
+			if index <= 0 || index >= glyphCount {
+				continue
+			}
+			tx := float32(index % atlasColumns * (glyphWidth + 1))
+			ty := float32(index / atlasColumns * (glyphHeight + 1))
+			glyphs = appendQuad(glyphs, float32(x)+float32(column)*advanceX, float32(y)+float32(row)*advanceY,
+				float32(glyphWidth*scale), float32(glyphHeight*scale), tx, ty, glyphWidth, glyphHeight)
+		}
+	}
+	vertices := append(background, glyphs...)
+
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	gl.UseProgram(t.program)
+	gl.Uniform2f(t.screenSize, float32(screenWidth), float32(screenHeight))
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, t.font)
+	gl.BindVertexArray(t.vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, t.vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*4, gl.Ptr(vertices), gl.STREAM_DRAW)
+
+	gl.Uniform1i(t.solid, 1)
+	gl.Uniform4f(t.color, 0, 0, 0, 0.6)
+	gl.DrawArrays(gl.TRIANGLES, 0, int32(len(background)/4))
+	gl.Uniform1i(t.solid, 0)
+	gl.Uniform4fv(t.color, 1, &color[0])
+	gl.DrawArrays(gl.TRIANGLES, int32(len(background)/4), int32(len(glyphs)/4))
+	gl.Disable(gl.BLEND)
+}
+
+// frameCounter measures the frame rate, averaged over half a second so that
+// it can be read.
+type frameCounter struct {
+	frames    int
+	elapsed   float64
+	fps       float64
+	frameTime float64
+}
+
+func (f *frameCounter) update(timeDelta float64) {
+	f.frames++
+	f.elapsed += timeDelta
+	if f.elapsed >= 0.5 {
+		f.fps = float64(f.frames) / f.elapsed
+		f.frameTime = f.elapsed / float64(f.frames)
+		f.frames, f.elapsed = 0, 0
+	}
+}
+
+// hudLines describes the state of the renderer for the HUD.
+func hudLines(shaderPath string, width, height int, counter frameCounter, stats *frameStats, clk *clock, cam camera, freeFlight bool, params *paramSet) []string {
+	mode := "standard"
+	if freeFlight {
+		mode = "free flight"
+	}
+	clockState := ""
+	if clk.paused {
+		clockState = ", paused"
+	}
+	lines := []string{
+		filepath.Base(shaderPath),
+		fmt.Sprintf("%.1f fps  %.2f ms  %dx%d", counter.fps, counter.frameTime*1000, width, height),
+	}
+	lines = append(lines, stats.lines()...)
+	lines = append(lines,
+		fmt.Sprintf("time       %.3f s, rate %.2f%s", clk.time, clk.rate, clockState),
+		fmt.Sprintf("position   %8.3f %8.3f %8.3f", cam.Position.x, cam.Position.y, cam.Position.z),
+		fmt.Sprintf("direction  %8.3f %8.3f %8.3f", cam.Direction.x, cam.Direction.y, cam.Direction.z),
+		fmt.Sprintf("camera     %s, fov %.1f", mode, cam.Fov*180/math32.Pi),
+		fmt.Sprintf("speed      %.3f (iSpeed %.3f)", cam.Speed, math32.Exp(cam.Speed-1)),
+		fmt.Sprintf("sliders    %.2f %.2f %.2f %.2f", cam.Sliders[0], cam.Sliders[1], cam.Sliders[2], cam.Sliders[3]),
+	)
+	for i, param := range params.params {
+		marker := " "
+		if i == params.selected {
+			marker = ">"
+		}
+		lines = append(lines, fmt.Sprintf("%s %-8s %s", marker, param.name, formatFloats(params.values[param.name])))
+	}
+	return lines
+}
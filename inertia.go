@@ -0,0 +1,33 @@
+package main
+
+import (
+	"math"
+)
+
+// inertia smooths a velocity towards the velocity the controls ask for. The
+// acceleration and damping are time constants in seconds, used while the
+// controls are held and released respectively. A time constant of 0 changes
+// the velocity instantly.
+type inertia struct {
+	acceleration, damping float64
+	velocity              vec3
+}
+
+// update moves the velocity towards target over timeDelta seconds and returns
+// it.
+func (i *inertia) update(target vec3, timeDelta float64) vec3 {
+	timeConstant := i.acceleration
+	if target == (vec3{}) {
+		timeConstant = i.damping
+	}
+	if timeConstant <= 0 {
+		i.velocity = target
+	} else {
+		i.velocity = i.velocity.lerp(target, float32(1-math.Exp(-timeDelta/timeConstant)))
+	}
+	return i.velocity
+}
+
+func (i *inertia) stop() {
+	i.velocity = vec3{}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// actionMap resolves the named actions the render loop queries into the keys
+// and gamepad buttons bound to them.
+type actionMap struct {
+	bindings map[string][]keyBinding
+	gamepad  *gamepad
+	// wasDown remembers which actions were down on the previous frame, for
+	// controls that act once per key press rather than while a key is held
+	wasDown map[string]bool
+}
+
+func newActionMap(bindings map[string][]keyBinding, gamepad *gamepad) *actionMap {
+	return &actionMap{bindings: bindings, gamepad: gamepad, wasDown: map[string]bool{}}
+}
+
+// down reports whether any key bound to action is held along with its
+// modifiers. A binding is ignored while a binding of the same key with more
+// modifiers is held, so that Ctrl+1 does not also trigger what 1 does.
+func (a *actionMap) down(window *glfw.Window, action string) bool {
+	held := heldModifiers(window)
+	for _, binding := range a.bindings[action] {
+		if binding.gamepad {
+			if a.gamepad.button(binding.button) {
+				return true
+			}
+			continue
+		}
+		if window.GetKey(binding.key) == glfw.Press && held&binding.modifiers == binding.modifiers && !a.shadowed(binding, held) {
+			return true
+		}
+	}
+	return false
+}
+
+// pressed reports whether action went down since the previous frame.
+func (a *actionMap) pressed(window *glfw.Window, action string) bool {
+	down := a.down(window, action)
+	wasDown := a.wasDown[action]
+	a.wasDown[action] = down
+	return down && !wasDown
+}
+
+func (a *actionMap) shadowed(binding keyBinding, held glfw.ModifierKey) bool {
+	for _, bindings := range a.bindings {
+		for _, other := range bindings {
+			if !other.gamepad && other.key == binding.key && other.modifiers != binding.modifiers &&
+				other.modifiers&binding.modifiers == binding.modifiers && held&other.modifiers == other.modifiers {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// modifierKeys are the keys that hold each modifier down.
+var modifierKeys = [...]struct {
+	modifier    glfw.ModifierKey
+	left, right glfw.Key
+}{
+	{glfw.ModShift, glfw.KeyLeftShift, glfw.KeyRightShift},
+	{glfw.ModControl, glfw.KeyLeftControl, glfw.KeyRightControl},
+	{glfw.ModAlt, glfw.KeyLeftAlt, glfw.KeyRightAlt},
+	{glfw.ModSuper, glfw.KeyLeftSuper, glfw.KeyRightSuper},
+}
+
+func heldModifiers(window *glfw.Window) glfw.ModifierKey {
+	var held glfw.ModifierKey
+	for _, keys := range modifierKeys {
+		if window.GetKey(keys.left) == glfw.Press || window.GetKey(keys.right) == glfw.Press {
+			held |= keys.modifier
+		}
+	}
+	return held
+}
@@ -4,9 +4,11 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"maps"
 	"math"
+	"os"
 	"runtime"
-	"strings"
+	"slices"
 	"time"
 
 	"github.com/chewxy/math32"
@@ -14,134 +16,80 @@ import (
 	"github.com/go-gl/glfw/v3.3/glfw"
 )
 
-func buildShader(vertexShaderSource, fragmentShaderSource string) uint32 {
-	vertex := gl.CreateShader(gl.VERTEX_SHADER)
-	cvs, freeVertex := gl.Strs(vertexShaderSource)
-	gl.ShaderSource(vertex, 1, cvs, nil)
-	freeVertex()
-	gl.CompileShader(vertex)
-	checkShaderCompileErrors(vertex, "VERTEX")
-
-	fragment := gl.CreateShader(gl.FRAGMENT_SHADER)
-	cfs, freeFragment := gl.Strs(fragmentShaderSource)
-	gl.ShaderSource(fragment, 1, cfs, nil)
-	freeFragment()
-	gl.CompileShader(fragment)
-	checkShaderCompileErrors(fragment, "FRAGMENT")
-
-	program := gl.CreateProgram()
-	gl.AttachShader(program, vertex)
-	gl.AttachShader(program, fragment)
-	gl.LinkProgram(program)
-	checkProgramLinkErrors(program)
-
-	gl.DeleteShader(vertex)
-	gl.DeleteShader(fragment)
-
-	return program
-}
-
-func checkShaderCompileErrors(shader uint32, shaderType string) {
-	var status int32
-	gl.GetShaderiv(shader, gl.COMPILE_STATUS, &status)
-	if status == gl.FALSE {
-		var logLength int32
-		gl.GetShaderiv(shader, gl.INFO_LOG_LENGTH, &logLength)
-		logMsg := strings.Repeat("\x00", int(logLength+1))
-		gl.GetShaderInfoLog(shader, logLength, nil, gl.Str(logMsg))
-		log.Printf("[%s SHADER COMPILE ERROR]:\n%s\n", shaderType, strings.TrimSpace(logMsg))
-	}
-}
-
-func checkProgramLinkErrors(program uint32) {
-	var status int32
-	gl.GetProgramiv(program, gl.LINK_STATUS, &status)
-	if status == gl.FALSE {
-		var logLength int32
-		gl.GetProgramiv(program, gl.INFO_LOG_LENGTH, &logLength)
-		logMsg := strings.Repeat("\x00", int(logLength+1))
-		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(logMsg))
-		log.Printf("[PROGRAM LINK ERROR]:\n%s\n", strings.TrimSpace(logMsg))
-	}
-}
-
 func main() {
 	runtime.LockOSThread()
-	if err := glfw.Init(); err != nil {
-		panic(err)
-	}
-	defer glfw.Terminate()
 
 	flags, err := NewFlags()
 	if err != nil {
-		fmt.Printf("%s\n", err.Error())
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
 		flag.Usage()
-		return
+		os.Exit(2)
 	}
 
-	glfw.WindowHint(glfw.ContextVersionMajor, 4)
-	glfw.WindowHint(glfw.ContextVersionMinor, 6)
-	glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
-	glfw.WindowHint(glfw.Decorated, glfw.True)
-	glfw.WindowHint(glfw.Resizable, glfw.True)
-
-	monitor := glfw.GetPrimaryMonitor()
-	mode := monitor.GetVideoMode()
-	renderWidth := flags.Width()
-	renderHeight := int(1. / flags.Ar() * float64(renderWidth))
-	windowWidth := mode.Width
-	windowHeight := mode.Height
-	if flags.Windowed() {
-		windowWidth = renderWidth
-		windowHeight = renderHeight
-		monitor = nil
-	}
-	window, err := glfw.CreateWindow(windowWidth, windowHeight, "Shader", monitor, nil)
+	bindings, err := loadBindings(flags.Bindings())
 	if err != nil {
-		panic(err)
+		fmt.Printf("%s\n", err.Error())
+		return
 	}
 
-	window.MakeContextCurrent()
+	renderWidth := flags.Width()
+	renderHeight := int(1. / flags.Ar() * float64(renderWidth))
 
-	window.SetInputMode(glfw.RawMouseMotion, glfw.True)
-	window.SetInputMode(glfw.CursorMode, glfw.CursorDisabled)
+	var window *glfw.Window
+	if flags.Headless() {
+		context, err := newHeadlessContext()
+		if err != nil {
+			panic(err)
+		}
+		defer context.destroy()
 
-	firstFrame := true
-	sensitivity := 0.003
-	startCameraPitch := math.Pi / 2
-	var startx, starty, cameraYaw, cameraYawDelta, cameraPitch, cameraPitchDelta float64
-	window.SetCursorPosCallback(func(w *glfw.Window, xpos, ypos float64) {
-		if firstFrame {
-			firstFrame = false
-			startx, starty = xpos, ypos
-			cameraYaw, cameraPitch, cameraYawDelta, cameraPitchDelta = 0, startCameraPitch, 0, 0
-			return
+		if err := gl.InitWithProcAddrFunc(context.getProcAddress); err != nil {
+			panic(err)
+		}
+	} else {
+		if err := glfw.Init(); err != nil {
+			panic(err)
 		}
+		defer glfw.Terminate()
 
-		cameraPitchDelta = (ypos-starty)*sensitivity + startCameraPitch - cameraPitch
-		cameraYawDelta = (xpos-startx)*sensitivity - cameraYaw
-		cameraYaw, cameraPitch = cameraYaw+cameraYawDelta, cameraPitch+cameraPitchDelta
-	})
+		glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
+		glfw.WindowHint(glfw.Decorated, glfw.True)
+		glfw.WindowHint(glfw.Resizable, glfw.True)
+		if flags.Offline() {
+			glfw.WindowHint(glfw.Visible, glfw.False)
+		}
 
-	glfw.SwapInterval(1)
+		monitor := glfw.GetPrimaryMonitor()
+		mode := monitor.GetVideoMode()
+		windowWidth := mode.Width
+		windowHeight := mode.Height
+		if flags.Windowed() || flags.Offline() {
+			windowWidth = renderWidth
+			windowHeight = renderHeight
+			monitor = nil
+		}
+		// Use the newest core profile the driver offers
+		for _, version := range contextVersions {
+			glfw.WindowHint(glfw.ContextVersionMajor, version.major)
+			glfw.WindowHint(glfw.ContextVersionMinor, version.minor)
+			window, err = glfw.CreateWindow(windowWidth, windowHeight, "Shader", monitor, nil)
+			if err == nil && window != nil {
+				break
+			}
+			log.Printf("OpenGL %s core context not available\n", version)
+		}
+		if window == nil {
+			panic(fmt.Errorf("failed to create an OpenGL context: %w", err))
+		}
 
-	if err := gl.Init(); err != nil {
-		panic(err)
-	}
+		window.MakeContextCurrent()
 
-	fragmentShaderSource, err := loadShaderSource(flags.Frag())
-	if err != nil {
-		panic(err)
-	}
-	shaderProgram := buildShader(`
-	#version 460 core
-	layout(location = 0) in vec2 pos;
-	void main() {
-		gl_Position = vec4(pos, 0.0, 1.0);
+		if err := gl.Init(); err != nil {
+			panic(err)
+		}
 	}
-	`+"\x00", fragmentShaderSource)
 
-	blitProgram := buildShader(`
+	blitProgram, err := buildShader(`
 	#version 460 core
 	layout(location = 0) in vec2 position;
 	layout(location = 1) in vec2 texCoord;
@@ -157,6 +105,9 @@ func main() {
 	void main() {
 		fragColor = texture(tex, uv);
 	}`+"\x00")
+	if err != nil {
+		panic(err)
+	}
 
 	quadVertices := []float32{-1, -1, 1, -1, -1, 1, 1, 1}
 	texCoords := []float32{0, 0, 1, 0, 0, 1, 1, 1}
@@ -186,142 +137,561 @@ func main() {
 	gl.VertexAttribPointer(1, 2, gl.FLOAT, false, 0, nil)
 	gl.EnableVertexAttribArray(1)
 
-	var tex uint32
-	gl.GenTextures(1, &tex)
-	gl.BindTexture(gl.TEXTURE_2D, tex)
-	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, int32(renderWidth), int32(renderHeight), 0, gl.RGBA, gl.UNSIGNED_BYTE, nil)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	passes, textures, err := newPassGraph(flags, renderWidth, renderHeight)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", describeError(err, os.Stderr))
+		os.Exit(1)
+	}
+
+	params := newParamSet(passes, flags.Params())
+	for _, name := range params.unknownOverrides() {
+		log.Printf("No shader declares the parameter %s\n", name)
+	}
+
+	if flags.Render() != "" {
+		if err := renderSequence(flags, passes, params, renderVAO); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flags.Poster() != "" {
+		if err := renderPoster(flags, passes[len(passes)-1], params, renderVAO); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flags.Bench() != "" {
+		regressed, err := runBenchmark(flags, passes, params, renderVAO)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			os.Exit(1)
+		}
+		// Regressions exit with a status of their own so that scripts can tell
+		// them from benchmarks that failed to run
+		if regressed {
+			os.Exit(exitRegression)
+		}
+		return
+	}
+
+	window.SetInputMode(glfw.RawMouseMotion, glfw.True)
+	window.SetInputMode(glfw.CursorMode, glfw.CursorDisabled)
+
+	firstFrame := true
+	sensitivity := 0.003
+	var startx, starty, cameraYaw, cameraYawDelta, cameraPitch, cameraPitchDelta float64
+	window.SetCursorPosCallback(func(w *glfw.Window, xpos, ypos float64) {
+		if firstFrame {
+			firstFrame = false
+			startx, starty = xpos, ypos
+			cameraYaw, cameraPitch, cameraYawDelta, cameraPitchDelta = 0, startCameraPitch, 0, 0
+			return
+		}
+
+		cameraPitchDelta = (ypos-starty)*sensitivity + startCameraPitch - cameraPitch
+		cameraYawDelta = (xpos-startx)*sensitivity - cameraYaw
+		cameraYaw, cameraPitch = cameraYaw+cameraYawDelta, cameraPitch+cameraPitchDelta
+	})
 
-	var fbo uint32
-	gl.GenFramebuffers(1, &fbo)
-	gl.BindFramebuffer(gl.FRAMEBUFFER, fbo)
-	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, tex, 0)
-	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	var scroll float64
+	window.SetScrollCallback(func(w *glfw.Window, xoff, yoff float64) {
+		scroll += yoff
+	})
+
+	glfw.SwapInterval(1)
+
+	image := passes[len(passes)-1]
+	// Passes are watched along with the files they include, which can change
+	// when they are reloaded
+	watchedFiles := func() []string {
+		var watched []string
+		for _, pass := range passes {
+			watched = append(watched, pass.files...)
+		}
+		for _, texture := range textures {
+			watched = append(watched, texture.path)
+		}
+		return watched
+	}
+	watcher := newFileWatcher(250*time.Millisecond, watchedFiles()...)
+
+	saved, err := loadBookmarks(bookmarksPath(flags.Frag()))
+	if err != nil {
+		log.Printf("%s\n", err.Error())
+		saved = &bookmarks{path: bookmarksPath(flags.Frag()), slots: map[int]camera{}}
+	}
+
+	flightPath := flags.CameraPath()
+	if flightPath == "" {
+		flightPath = cameraPathFile(flags.Frag())
+	}
+	flight, err := loadCameraPath(flightPath, false)
+	if err != nil {
+		log.Printf("%s\n", err.Error())
+		flight = &cameraPath{path: flightPath}
+	}
+	var lastKeyframe, playbackStart time.Time
+	playing := false
+
+	// Rates of change per second of the keyboard controls, which match what
+	// they used to change per frame at 60 frames per second. Analog controls
+	// change at the same rates when fully deflected, and the look stick turns
+	// the camera at lookRate radians per second
+	rollRate := 1.2
+	lookRate := 2.5
+	movementRate := float32(60)
+	speedRate := float32(0.6)
+	sliderRate := float32(60)
+	// Frames taking longer than this, like those recompiling a shader, do not
+	// make the camera jump
+	maxInputDelta := 0.1
+	movementInertia := inertia{acceleration: flags.Acceleration(), damping: flags.Damping()}
+	movementFixedInertia := movementInertia
 
 	start := time.Now()
-	speed := float32(1)
-	cameraPosition := vec3{0, 0, 0}
-	cameraPositionFixed := cameraPosition
-	cameraDirection := vec3{0, 0, 1}
-	upDirection := vec3{0, 1, 0}
-	u := vec3{1, 0, 0}
-	// v := upDirection
-	clampedCameraPitch := startCameraPitch
-	sliderx, slidery, sliderz, sliderw := float32(0), float32(0), float32(0), float32(0)
-
-	iTimeLoc := gl.GetUniformLocation(shaderProgram, gl.Str("iTime\x00"))
-	iSpeedLoc := gl.GetUniformLocation(shaderProgram, gl.Str("iSpeed\x00"))
-	iResolutionLoc := gl.GetUniformLocation(shaderProgram, gl.Str("iResolution\x00"))
-	iPositionLoc := gl.GetUniformLocation(shaderProgram, gl.Str("iPosition\x00"))
-	iPositionFixedLoc := gl.GetUniformLocation(shaderProgram, gl.Str("iPositionFixed\x00"))
-	iDirectionLoc := gl.GetUniformLocation(shaderProgram, gl.Str("iDirection\x00"))
-	iSlidersLoc := gl.GetUniformLocation(shaderProgram, gl.Str("iSliders\x00"))
+	clk := newClock(flags.StartTime(), flags.Loop())
+	cam := newCamera(flags.Fov())
+	var transition *cameraTransition
+	freeFlight := flags.FreeFlight()
+
+	adjustingParam := false
+
+	text, err := newTextRenderer()
+	if err != nil {
+		panic(err)
+	}
+	buildErrors, err := newErrorScreen()
+	if err != nil {
+		panic(err)
+	}
+	hudVisible := flags.Hud()
+	var counter frameCounter
+
+	timer := newFrameTimer()
+	var scaler *resolutionScaler
+	if flags.TargetFps() > 0 {
+		scaler = newResolutionScaler(renderWidth, renderHeight, flags.TargetFps())
+	}
+	var stats frameStats
+	var timings *timingsCSV
+	if flags.Timings() != "" {
+		timings, err = newTimingsCSV(flags.Timings())
+		if err != nil {
+			fmt.Printf("%s\n", err.Error())
+			return
+		}
+		defer func() {
+			if err := timings.close(); err != nil {
+				log.Printf("%s\n", err.Error())
+			}
+		}()
+	}
+
+	var mouse shadertoyMouse
+	pad := newGamepad(flags.Deadzone(), flags.AxisSensitivity())
+	actions := newActionMap(bindings, pad)
+	frame := 0
+	// shaderFrame is iFrame, which only counts the frames the clock moved on,
+	// so that buffer passes hold their state while time is paused
+	shaderFrame := 0
+	// The camera, mouse and parameters the buffer passes last rendered with
+	var bufferCamera camera
+	var bufferMouse [4]float32
+	var bufferParams map[string]float32
+	lastFrame := start
 
 	for !window.ShouldClose() {
-		gl.BindFramebuffer(gl.FRAMEBUFFER, fbo)
-		gl.Viewport(0, 0, int32(renderWidth), int32(renderHeight))
-		gl.ClearColor(0, 0, 0, 1)
-		gl.Clear(gl.COLOR_BUFFER_BIT)
-		gl.UseProgram(shaderProgram)
+		now := time.Now()
+		frameDelta := now.Sub(lastFrame).Seconds()
+		inputDelta := math.Min(frameDelta, maxInputDelta)
+		lastFrame = now
+		pad.poll()
+		counter.update(frameDelta)
+
+		// Passes keep rendering their last program that linked if an edit broke
+		// the shader
+		changed := watcher.changes()
+		rewatch := false
+		for _, pass := range passes {
+			if !slices.ContainsFunc(changed, func(path string) bool { return slices.Contains(pass.files, path) }) {
+				continue
+			}
+			if err := pass.reload(); err != nil {
+				log.Printf("%s\n", describeError(err, os.Stderr))
+			} else {
+				log.Printf("Reloaded %s\n", pass.path)
+				params.sync(passes)
+			}
+			rewatch = true
+		}
+		if rewatch {
+			watcher.watch(watchedFiles()...)
+		}
+		for _, path := range changed {
+			for _, texture := range textures {
+				if texture.path != path {
+					continue
+				}
+				if err := texture.reload(); err != nil {
+					log.Printf("%s\n", err.Error())
+				} else {
+					log.Printf("Reloaded %s\n", path)
+				}
+			}
+		}
 
-		if window.GetKey(glfw.KeyEscape) == glfw.Press {
+		if actions.down(window, "quit") {
 			window.SetShouldClose(true)
 		}
 
-		// Rotation
-		tempClampedCameraPitch := math.Max(0.001, math.Min(math.Pi-0.001, clampedCameraPitch+cameraPitchDelta))
-		cameraDirection = cameraDirection.rotateAroundAxis(u, float32(tempClampedCameraPitch-clampedCameraPitch)).rotateAroundAxis(upDirection, float32(cameraYawDelta))
-		u = upDirection.cross(cameraDirection).normalize()
-		// v = cameraDirection.cross(u)
-		clampedCameraPitch = tempClampedCameraPitch
+		// Bookmarks, stored with Ctrl+1..9 and recalled with 1..9 by default
+		for slot := 1; slot <= bookmarkSlots; slot++ {
+			if actions.pressed(window, fmt.Sprintf("bookmark-store-%d", slot)) {
+				if err := saved.set(slot, cam); err != nil {
+					log.Printf("%s\n", err.Error())
+				} else {
+					log.Printf("Stored bookmark %d\n", slot)
+				}
+			}
+			if !actions.pressed(window, fmt.Sprintf("bookmark-recall-%d", slot)) {
+				continue
+			}
+			if bookmark, ok := saved.get(slot); ok {
+				transition = &cameraTransition{
+					from:     cam,
+					to:       bookmark,
+					start:    time.Now(),
+					duration: time.Duration(flags.BookmarkTransition() * float64(time.Second)),
+				}
+			}
+		}
+
+		// Camera path keyframes are added with K and removed with Backspace by
+		// default.
+		// Keyframes are as far apart in time as the key presses, and the first
+		// one added to a path loaded from disk comes a second after its end
+		if actions.pressed(window, "keyframe-add") {
+			keyframe := cameraKeyframe{Camera: cam}
+			if len(flight.keyframes) > 0 {
+				gap := time.Second
+				if !lastKeyframe.IsZero() {
+					gap = time.Since(lastKeyframe)
+				}
+				keyframe.Time = flight.duration() + gap.Seconds()
+			}
+			lastKeyframe = time.Now()
+			flight.keyframes = append(flight.keyframes, keyframe)
+			if err := flight.save(); err != nil {
+				log.Printf("%s\n", err.Error())
+			} else {
+				log.Printf("Added keyframe %d at %.2fs\n", len(flight.keyframes), keyframe.Time)
+			}
+		}
+		if actions.pressed(window, "keyframe-remove") && len(flight.keyframes) > 0 {
+			flight.keyframes = flight.keyframes[:len(flight.keyframes)-1]
+			if err := flight.save(); err != nil {
+				log.Printf("%s\n", err.Error())
+			} else {
+				log.Printf("Removed keyframe %d\n", len(flight.keyframes)+1)
+			}
+		}
+		// P starts playing the camera path back from the start, or stops it
+		if actions.pressed(window, "path-play") {
+			playing = !playing && len(flight.keyframes) > 0
+			playbackStart = time.Now()
+			transition = nil
+		}
+
+		// F switches between the free-flight and the standard camera, which
+		// levels the camera out again
+		if actions.pressed(window, "free-flight") {
+			freeFlight = !freeFlight
+			if !freeFlight {
+				cam.level()
+			}
+		}
+
+		cameraPitchDelta += float64(pad.axis("look-y")) * lookRate * inputDelta
+		cameraYawDelta += float64(pad.axis("look-x")) * lookRate * inputDelta
+
+		// Rotation, which the controls take no part in during a transition or
+		// a camera path playback
+		steered := playing || transition != nil
+		if playing {
+			elapsed := time.Since(playbackStart).Seconds()
+			cam = flight.at(elapsed)
+			if elapsed >= flight.duration() {
+				playing = false
+				log.Printf("Camera path playback finished\n")
+			}
+		} else if transition != nil {
+			var done bool
+			if cam, done = transition.at(time.Now()); done {
+				transition = nil
+			}
+		} else if freeFlight {
+			roll := 0.
+			if actions.down(window, "roll-left") {
+				roll -= rollRate * inputDelta
+			}
+			if actions.down(window, "roll-right") {
+				roll += rollRate * inputDelta
+			}
+			cam.rotateFree(cameraPitchDelta, cameraYawDelta, roll)
+		} else {
+			cam.rotate(cameraPitchDelta, cameraYawDelta)
+		}
+		if steered && !freeFlight {
+			cam.level()
+		}
 		cameraPitchDelta, cameraYawDelta = 0, 0
 
+		// Scrolling up zooms in
+		if !steered {
+			cam.zoom(scroll)
+		}
+		scroll = 0
+
 		// Movement
 		movement := vec3{0, 0, 0}
 		movementFixed := movement
 		movementScale := float32(1.0)
-		if window.GetKey(glfw.KeyLeftControl) == glfw.Press {
+		if actions.down(window, "move-slow") {
 			movementScale = 0.2
 		}
-		if window.GetKey(glfw.KeyW) == glfw.Press {
-			movement = movement.add(cameraDirection.scale(1.5))
+		// The free-flight camera moves up and down along its own up vector
+		up := upDirection
+		if freeFlight {
+			up = cam.Up
+		}
+		if actions.down(window, "move-forward") {
+			movement = movement.add(cam.Direction.scale(1.5))
 			movementFixed = movementFixed.add(vec3{0, 0, 1.5})
 		}
-		if window.GetKey(glfw.KeyS) == glfw.Press {
-			movement = movement.add(cameraDirection.scale(-1))
+		if actions.down(window, "move-backward") {
+			movement = movement.add(cam.Direction.scale(-1))
 			movementFixed = movementFixed.add(vec3{0, 0, -1})
 		}
-		if window.GetKey(glfw.KeyA) == glfw.Press {
-			movement = movement.add(u.scale(-1))
+		if actions.down(window, "move-left") {
+			movement = movement.add(cam.U.scale(-1))
 			movementFixed = movementFixed.add(vec3{-1, 0, 0})
 		}
-		if window.GetKey(glfw.KeyD) == glfw.Press {
-			movement = movement.add(u.scale(1))
+		if actions.down(window, "move-right") {
+			movement = movement.add(cam.U.scale(1))
 			movementFixed = movementFixed.add(vec3{1, 0, 0})
 		}
-		if window.GetKey(glfw.KeySpace) == glfw.Press {
-			movement = movement.add(upDirection.scale(1))
+		if actions.down(window, "move-up") {
+			movement = movement.add(up.scale(1))
 			movementFixed = movementFixed.add(vec3{0, 1, 0})
 		}
-		if window.GetKey(glfw.KeyLeftShift) == glfw.Press {
-			movement = movement.add(upDirection.scale(-1))
+		if actions.down(window, "move-down") {
+			movement = movement.add(up.scale(-1))
 			movementFixed = movementFixed.add(vec3{0, -1, 0})
 		}
-		if window.GetKey(glfw.KeyQ) == glfw.Press {
-			speed -= 0.01
+		// Analog movement, which moves forward faster than backward like the
+		// keyboard does
+		forward := pad.axis("move-y")
+		if forward > 0 {
+			forward *= 1.5
+		}
+		right, rise := pad.axis("move-x"), pad.axis("up")-pad.axis("down")
+		movement = movement.add(cam.Direction.scale(forward)).add(cam.U.scale(right)).add(up.scale(rise))
+		movementFixed = movementFixed.add(vec3{right, rise, forward})
+		if actions.down(window, "speed-down") {
+			cam.Speed -= speedRate * float32(inputDelta)
 		}
-		if window.GetKey(glfw.KeyE) == glfw.Press {
-			speed += 0.01
+		if actions.down(window, "speed-up") {
+			cam.Speed += speedRate * float32(inputDelta)
 		}
-		if window.GetKey(glfw.KeyKPSubtract) == glfw.Press {
-			sliderx--
+		sliderDelta := sliderRate * float32(inputDelta)
+		if actions.down(window, "slider-x-dec") {
+			cam.Sliders[0] -= sliderDelta
 		}
-		if window.GetKey(glfw.KeyKPAdd) == glfw.Press {
-			sliderx++
+		if actions.down(window, "slider-x-inc") {
+			cam.Sliders[0] += sliderDelta
 		}
-		if window.GetKey(glfw.KeyDown) == glfw.Press {
-			slidery--
+		if actions.down(window, "slider-y-dec") {
+			cam.Sliders[1] -= sliderDelta
 		}
-		if window.GetKey(glfw.KeyUp) == glfw.Press {
-			slidery++
+		if actions.down(window, "slider-y-inc") {
+			cam.Sliders[1] += sliderDelta
 		}
-		if window.GetKey(glfw.KeyLeft) == glfw.Press {
-			sliderz--
+		if actions.down(window, "slider-z-dec") {
+			cam.Sliders[2] -= sliderDelta
 		}
-		if window.GetKey(glfw.KeyRight) == glfw.Press {
-			sliderz++
+		if actions.down(window, "slider-z-inc") {
+			cam.Sliders[2] += sliderDelta
 		}
-		if window.GetKey(glfw.KeyPageDown) == glfw.Press {
-			sliderw--
+		if actions.down(window, "slider-w-dec") {
+			cam.Sliders[3] -= sliderDelta
 		}
-		if window.GetKey(glfw.KeyPageUp) == glfw.Press {
-			sliderw++
+		if actions.down(window, "slider-w-inc") {
+			cam.Sliders[3] += sliderDelta
+		}
+		cam.Sliders[2] += sliderDelta * pad.axis("slider-z")
+		cam.Sliders[3] += sliderDelta * pad.axis("slider-w")
+		if steered {
+			movementInertia.stop()
+			movementFixedInertia.stop()
+		} else {
+			scale := movementRate * movementScale * math32.Exp(cam.Speed-1)
+			velocity := movementInertia.update(movement.scale(scale), inputDelta)
+			velocityFixed := movementFixedInertia.update(movementFixed.scale(scale), inputDelta)
+			cam.Position = cam.Position.add(velocity.scale(float32(inputDelta)))
+			cam.PositionFixed = cam.PositionFixed.add(velocityFixed.scale(float32(inputDelta)))
 		}
-		cameraPosition = cameraPosition.add(movement.scale(movementScale * math32.Exp(speed-1)))
-		cameraPositionFixed = cameraPositionFixed.add(movementFixed.scale(movementScale * math32.Exp(speed-1)))
 
-		gl.Uniform1f(iTimeLoc, float32(time.Since(start).Seconds()))
-		gl.Uniform1f(iSpeedLoc, math32.Exp(speed-1))
-		gl.Uniform2f(iResolutionLoc, float32(renderWidth), float32(renderHeight))
-		gl.Uniform3f(iPositionLoc, cameraPosition.x, cameraPosition.y, cameraPosition.z)
-		gl.Uniform3f(iPositionFixedLoc, cameraPositionFixed.x, cameraPositionFixed.y, cameraPositionFixed.z)
-		gl.Uniform3f(iDirectionLoc, cameraDirection.x, cameraDirection.y, cameraDirection.z)
-		gl.Uniform4f(iSlidersLoc, sliderx, slidery, sliderz, sliderw)
+		// Time controls. Stepping pauses the clock
+		timeDelta := clk.advance(frameDelta)
+		ticked := timeDelta != 0
+		if actions.pressed(window, "time-pause") {
+			clk.togglePause()
+		}
+		if actions.pressed(window, "time-step-back") {
+			timeDelta += clk.step(-1)
+			ticked = true
+		}
+		if actions.pressed(window, "time-step-forward") {
+			timeDelta += clk.step(1)
+			ticked = true
+		}
+		if actions.pressed(window, "time-rewind") {
+			timeDelta += clk.rewind()
+			ticked = true
+		}
+		if actions.pressed(window, "time-rate-down") {
+			clk.changeRate(-1)
+			log.Printf("Time rate %.2f\n", clk.rate)
+		}
+		if actions.pressed(window, "time-rate-up") {
+			clk.changeRate(1)
+			log.Printf("Time rate %.2f\n", clk.rate)
+		}
+		if actions.pressed(window, "time-rate-reset") {
+			clk.resetRate()
+			log.Printf("Time rate %.2f\n", clk.rate)
+		}
+
+		// Shader parameters are selected by name and adjusted one at a time.
+		// Their value is logged once adjusting them stops
+		if actions.pressed(window, "param-prev") {
+			params.selectNext(-1)
+			log.Printf("Selected %s\n", params.describeSelected())
+		}
+		if actions.pressed(window, "param-next") {
+			params.selectNext(1)
+			log.Printf("Selected %s\n", params.describeSelected())
+		}
+		paramDirection := float32(0)
+		if actions.down(window, "param-dec") {
+			paramDirection--
+		}
+		if actions.down(window, "param-inc") {
+			paramDirection++
+		}
+		params.adjust(paramDirection, inputDelta)
+		if adjustingParam && paramDirection == 0 {
+			log.Printf("Set %s\n", params.describeSelected())
+		}
+		adjustingParam = paramDirection != 0
+
+		mouse.update(window, image.target.width, image.target.height)
+
+		state := cam.frameState()
+		state.time = float32(clk.time)
+		state.timeDelta = float32(timeDelta)
+		if frameDelta > 0 {
+			state.frameRate = float32(1 / frameDelta)
+		}
+		state.frame = shaderFrame
+		state.mouse = mouse.uniform()
+		state.date = shadertoyDate(start.Add(time.Duration((clk.time - clk.start) * float64(time.Second))))
+		state.params = params.values
+
+		// GPU timings are read a few frames late, once they are available
+		for _, timing := range timer.poll() {
+			stats.add(timing)
+			if timings != nil {
+				timings.write(timing)
+			}
+			// Every pass renders at the same size, which iResolution follows
+			if scaler != nil && scaler.update(timing, frame) {
+				width, height := scaler.size()
+				for _, pass := range passes {
+					pass.target.resize(width, height)
+				}
+				log.Printf("Render resolution %dx%d\n", width, height)
+			}
+		}
+		timer.begin(frame, state.time, frameDelta)
+
+		// While the clock stands still, buffer passes only redraw when the
+		// camera, mouse, parameters or shaders they may depend on change, and
+		// iFrame holds. Buffers reading their own previous frame still step
+		// each time they redraw
+		timer.beginSection(timerPasses)
+		rendered := passes
+		if !ticked && !rewatch && cam == bufferCamera && state.mouse == bufferMouse && maps.Equal(params.values, bufferParams) {
+			rendered = passes[len(passes)-1:]
+		} else {
+			bufferCamera, bufferMouse, bufferParams = cam, state.mouse, maps.Clone(params.values)
+		}
+		for _, pass := range rendered {
+			pass.render(renderVAO, state)
+		}
+		timer.endSection()
+		frame++
+		if ticked {
+			shaderFrame++
+		}
+
+		// Screenshots are taken once per key press
+		if actions.pressed(window, "screenshot") {
+			metadata := frameMetadata(flags.Frag(), image.target.width, image.target.height, state, cam.Speed)
+			if path, err := saveScreenshot(flags.ScreenshotDir(), flags.Frag(), image.target, metadata); err != nil {
+				log.Printf("%s\n", err.Error())
+			} else {
+				log.Printf("Saved screenshot %s\n", path)
+			}
+		}
 
-		gl.BindVertexArray(renderVAO)
-		gl.DrawArrays(gl.TRIANGLE_STRIP, 0, 4)
-		gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
 		w, h := window.GetFramebufferSize()
 		gl.Viewport(0, 0, int32(w), int32(h))
 		gl.ClearColor(0, 0, 0, 1)
 		gl.Clear(gl.COLOR_BUFFER_BIT)
 		gl.UseProgram(blitProgram)
 		gl.ActiveTexture(gl.TEXTURE0)
-		gl.BindTexture(gl.TEXTURE_2D, tex)
+		gl.BindTexture(gl.TEXTURE_2D, image.target.texture())
 		gl.BindVertexArray(blitVAO)
+		timer.beginSection(timerBlit)
 		gl.DrawArrays(gl.TRIANGLE_STRIP, 0, 4)
+		timer.endSection()
+
+		// Passes that failed to rebuild show why over their last working
+		// version until they are fixed, in place of the HUD
+		showingErrors := buildErrors.draw(passes, text, blitVAO, w, h)
+
+		// The HUD is drawn at window resolution, whatever the render resolution
+		if actions.pressed(window, "hud") {
+			hudVisible = !hudVisible
+		}
+		if hudVisible && !showingErrors {
+			scale := max(1, h/480)
+			text.draw(hudLines(flags.Frag(), image.target.width, image.target.height, counter, &stats, clk, cam, freeFlight, params), 6*scale, 6*scale, scale, w, h, [4]float32{1, 1, 1, 1})
+		}
+		timer.end(time.Since(now).Seconds())
 		window.SwapBuffers()
 		glfw.PollEvents()
 	}
+
+	// The last frames are still being timed when the window closes
+	if timings != nil {
+		for _, timing := range timer.flush() {
+			timings.write(timing)
+		}
+	}
 }
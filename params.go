@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// paramPattern matches float uniforms annotated as parameters, as in
+// "uniform float lod; // @param min=0 max=4 default=1 step=0.01".
+var paramPattern = regexp.MustCompile(`(?m)^[ \t]*uniform[ \t]+float[ \t]+(\w+)[ \t]*;[ \t]*//[ \t]*@param\b(.*)$`)
+
+// shaderParam is a float uniform a shader declares as adjustable. Holding a
+// key to adjust it changes it by step every 60th of a second.
+type shaderParam struct {
+	name         string
+	min, max     float32
+	defaultValue float32
+	step         float32
+}
+
+// parseShaderParams returns the parameters source declares, in order.
+func parseShaderParams(source string) ([]shaderParam, error) {
+	var params []shaderParam
+	for _, match := range paramPattern.FindAllStringSubmatch(source, -1) {
+		param := shaderParam{name: match[1], min: -math.MaxFloat32, max: math.MaxFloat32, step: 0.01}
+		hasDefault := false
+		for _, option := range strings.Fields(match[2]) {
+			key, value, found := strings.Cut(option, "=")
+			parsed, err := strconv.ParseFloat(value, 32)
+			if !found || err != nil {
+				return nil, fmt.Errorf("error: Invalid option %q of parameter %s, expected KEY=NUMBER", option, param.name)
+			}
+			switch key {
+			case "min":
+				param.min = float32(parsed)
+			case "max":
+				param.max = float32(parsed)
+			case "default":
+				param.defaultValue, hasDefault = float32(parsed), true
+			case "step":
+				param.step = float32(parsed)
+			default:
+				return nil, fmt.Errorf("error: Unknown option %q of parameter %s, expected min, max, default or step", key, param.name)
+			}
+		}
+		if param.min > param.max {
+			return nil, fmt.Errorf("error: Parameter %s has a min greater than its max", param.name)
+		}
+		if !hasDefault {
+			param.defaultValue = param.clamp(0)
+		}
+		if param.defaultValue != param.clamp(param.defaultValue) {
+			return nil, fmt.Errorf("error: Default of parameter %s is outside of its range", param.name)
+		}
+		params = append(params, param)
+	}
+	return params, nil
+}
+
+func (p shaderParam) clamp(value float32) float32 {
+	return max(p.min, min(p.max, value))
+}
+
+// paramSet holds the values of the parameters of all passes. Passes declaring
+// a parameter with the same name share its value.
+type paramSet struct {
+	params []shaderParam
+	values map[string]float32
+	// overrides are the values set on the command line, used instead of the
+	// defaults
+	overrides map[string]float32
+	selected  int
+}
+
+func newParamSet(passes []*renderPass, overrides map[string]float32) *paramSet {
+	set := &paramSet{values: map[string]float32{}, overrides: overrides}
+	set.sync(passes)
+	return set
+}
+
+// sync updates the set after passes were reloaded. Parameters that are still
+// declared keep their values, clamped to their new range.
+func (s *paramSet) sync(passes []*renderPass) {
+	var selected string
+	if s.selected < len(s.params) {
+		selected = s.params[s.selected].name
+	}
+
+	s.params = nil
+	values := map[string]float32{}
+	for _, pass := range passes {
+		for _, param := range pass.params {
+			if _, ok := values[param.name]; ok {
+				continue
+			}
+			value, ok := s.values[param.name]
+			if !ok {
+				value, ok = s.overrides[param.name]
+			}
+			if !ok {
+				value = param.defaultValue
+			}
+			values[param.name] = param.clamp(value)
+			s.params = append(s.params, param)
+		}
+	}
+	s.values = values
+
+	s.selected = 0
+	for i, param := range s.params {
+		if param.name == selected {
+			s.selected = i
+		}
+	}
+}
+
+// unknownOverrides returns the names of the parameters set on the command
+// line that no pass declares.
+func (s *paramSet) unknownOverrides() []string {
+	var unknown []string
+	for name := range s.overrides {
+		if _, ok := s.values[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// selectNext moves the selection by offset parameters, wrapping around.
+func (s *paramSet) selectNext(offset int) {
+	if len(s.params) > 0 {
+		s.selected = ((s.selected+offset)%len(s.params) + len(s.params)) % len(s.params)
+	}
+}
+
+// adjust changes the selected parameter by direction steps per 60th of a
+// second, over timeDelta seconds.
+func (s *paramSet) adjust(direction float32, timeDelta float64) {
+	if len(s.params) == 0 || direction == 0 {
+		return
+	}
+	param := s.params[s.selected]
+	s.values[param.name] = param.clamp(s.values[param.name] + direction*param.step*60*float32(timeDelta))
+}
+
+// describeSelected describes the selected parameter and its value.
+func (s *paramSet) describeSelected() string {
+	if len(s.params) == 0 {
+		return "No parameters"
+	}
+	param := s.params[s.selected]
+	return fmt.Sprintf("%s = %s", param.name, formatFloats(s.values[param.name]))
+}
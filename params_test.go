@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestParseShaderParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    []shaderParam
+		wantErr string
+	}{
+		{
+			name:   "all options",
+			source: "uniform float lod; // @param min=0.25 max=4 default=1 step=0.01\n",
+			want:   []shaderParam{{name: "lod", min: 0.25, max: 4, defaultValue: 1, step: 0.01}},
+		},
+		{
+			name:   "no options",
+			source: "  uniform float gain;//@param\n",
+			want:   []shaderParam{{name: "gain", min: -math.MaxFloat32, max: math.MaxFloat32, step: 0.01}},
+		},
+		{
+			name:   "default clamped into the range",
+			source: "uniform float height; // @param min=2 max=3\n",
+			want:   []shaderParam{{name: "height", min: 2, max: 3, defaultValue: 2, step: 0.01}},
+		},
+		{
+			name:   "in order, ignoring uniforms without annotation",
+			source: "uniform float b; // @param\nuniform float plain;\nuniform vec2 v; // @param\nuniform float a; // @param step=1\n",
+			want: []shaderParam{
+				{name: "b", min: -math.MaxFloat32, max: math.MaxFloat32, step: 0.01},
+				{name: "a", min: -math.MaxFloat32, max: math.MaxFloat32, step: 1},
+			},
+		},
+		{
+			name:    "invalid number",
+			source:  "uniform float lod; // @param max=four\n",
+			wantErr: "error: Invalid option \"max=four\" of parameter lod, expected KEY=NUMBER",
+		},
+		{
+			name:    "unknown option",
+			source:  "uniform float lod; // @param speed=1\n",
+			wantErr: "error: Unknown option \"speed\" of parameter lod, expected min, max, default or step",
+		},
+		{
+			name:    "empty range",
+			source:  "uniform float lod; // @param min=2 max=1\n",
+			wantErr: "error: Parameter lod has a min greater than its max",
+		},
+		{
+			name:    "default outside of the range",
+			source:  "uniform float lod; // @param min=0 max=1 default=2\n",
+			wantErr: "error: Default of parameter lod is outside of its range",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			params, err := parseShaderParams(test.source)
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Errorf("error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if !slices.Equal(params, test.want) {
+				t.Errorf("params = %+v, want %+v", params, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"image"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+const channelCount = 4
+
+// bufferNames are the buffer passes in the order they are rendered, before
+// the image pass.
+var bufferNames = [...]string{"A", "B", "C", "D"}
+
+const imagePassName = "image"
+
+// channelSource is anything that can be bound to one of a pass' iChannel
+// samplers.
+type channelSource interface {
+	texture() uint32
+	size() (int, int)
+}
+
+// renderTarget is a double-buffered offscreen color target. A pass renders
+// into the back texture while the front one still holds its previous output,
+// so a pass can read its own last frame.
+type renderTarget struct {
+	width, height  int
+	internalFormat int32
+	pixelType      uint32
+	filter         int32
+	textures       [2]uint32
+	fbos           [2]uint32
+	front          int
+}
+
+func newRenderTarget(width, height int, internalFormat int32, pixelType uint32, filter int32) *renderTarget {
+	t := &renderTarget{
+		width:          width,
+		height:         height,
+		internalFormat: internalFormat,
+		pixelType:      pixelType,
+		filter:         filter,
+	}
+	gl.GenTextures(2, &t.textures[0])
+	gl.GenFramebuffers(2, &t.fbos[0])
+	for i := range t.textures {
+		gl.BindTexture(gl.TEXTURE_2D, t.textures[i])
+		gl.TexImage2D(gl.TEXTURE_2D, 0, internalFormat, int32(width), int32(height), 0, gl.RGBA, pixelType, nil)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, filter)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, filter)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+
+		gl.BindFramebuffer(gl.FRAMEBUFFER, t.fbos[i])
+		gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, t.textures[i], 0)
+		gl.ClearColor(0, 0, 0, 0)
+		gl.Clear(gl.COLOR_BUFFER_BIT)
+	}
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	return t
+}
+
+// texture returns the front texture, holding the last completed frame.
+func (t *renderTarget) texture() uint32 {
+	return t.textures[t.front]
+}
+
+func (t *renderTarget) size() (int, int) {
+	return t.width, t.height
+}
+
+// framebuffer returns the framebuffer rendering into the back texture.
+func (t *renderTarget) framebuffer() uint32 {
+	return t.fbos[1-t.front]
+}
+
+// swap makes the texture that was just rendered into the front one.
+func (t *renderTarget) swap() {
+	t.front = 1 - t.front
+}
+
+// readPixels reads the front texture back as an opaque 8-bit image, top row
+// first.
+func (t *renderTarget) readPixels() *image.NRGBA {
+	pixels := image.NewNRGBA(image.Rect(0, 0, t.width, t.height))
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, t.fbos[t.front])
+	gl.PixelStorei(gl.PACK_ALIGNMENT, 1)
+	gl.ReadPixels(0, 0, int32(t.width), int32(t.height), gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(pixels.Pix))
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, 0)
+
+	flipRows(pixels.Pix, pixels.Stride, t.height)
+	// The blit ignores alpha, so make the image look like it does on screen
+	for i := 3; i < len(pixels.Pix); i += 4 {
+		pixels.Pix[i] = 255
+	}
+	return pixels
+}
+
+// resize reallocates the target at a new size. The last frame is scaled
+// into it, for passes that read their own output.
+func (t *renderTarget) resize(width, height int) {
+	resized := newRenderTarget(width, height, t.internalFormat, t.pixelType, t.filter)
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, t.fbos[t.front])
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, resized.fbos[resized.front])
+	gl.BlitFramebuffer(0, 0, int32(t.width), int32(t.height), 0, 0, int32(width), int32(height), gl.COLOR_BUFFER_BIT, gl.LINEAR)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	t.delete()
+	*t = *resized
+}
+
+func (t *renderTarget) delete() {
+	gl.DeleteFramebuffers(2, &t.fbos[0])
+	gl.DeleteTextures(2, &t.textures[0])
+}
+
+// renderPass is one user shader rendering into its own target, reading up
+// to four channel sources. files are the shader and the files it includes,
+// as of the last reload, and buildErr why that reload failed, if it did.
+type renderPass struct {
+	name     string
+	path     string
+	files    []string
+	buildErr error
+	options  shaderOptions
+	program  uint32
+	uniforms uniforms
+	params   []shaderParam
+	target   *renderTarget
+	channels [channelCount]channelSource
+}
+
+// newRenderPass builds the pass' program, failing if it does not build so
+// that a broken shader is not silently rendered as nothing.
+func newRenderPass(name, path string, target *renderTarget, options shaderOptions) (*renderPass, error) {
+	pass := &renderPass{name: name, path: path, files: []string{path}, target: target, options: options}
+	if err := pass.reload(); err != nil {
+		return nil, err
+	}
+	return pass, nil
+}
+
+// reload rebuilds the pass' program from its source files. If the new source
+// fails to build the previous program is kept.
+func (p *renderPass) reload() error {
+	program, params, files, err := loadShaderProgram(p.path, p.options)
+	if len(files) > 0 {
+		p.files = files
+	}
+	p.buildErr = err
+	if err != nil {
+		return err
+	}
+	gl.DeleteProgram(p.program)
+	p.program = program
+	p.params = params
+	p.uniforms = getUniformLocations(program, params)
+	return nil
+}
+
+// render draws the pass into the back texture of its target and swaps it to
+// the front, so later passes in the same frame read this frame's output.
+func (p *renderPass) render(vao uint32, state frameState) {
+	gl.BindFramebuffer(gl.FRAMEBUFFER, p.target.framebuffer())
+	gl.Viewport(0, 0, int32(p.target.width), int32(p.target.height))
+	gl.ClearColor(0, 0, 0, 1)
+	gl.Clear(gl.COLOR_BUFFER_BIT)
+	gl.UseProgram(p.program)
+
+	state.width, state.height = p.target.size()
+	if state.tile != nil {
+		state.width, state.height = state.tile.width, state.tile.height
+	}
+	p.uniforms.upload(state)
+
+	var channelResolution [channelCount * 3]float32
+	for i, channel := range p.channels {
+		if channel == nil {
+			continue
+		}
+		gl.ActiveTexture(gl.TEXTURE0 + uint32(i))
+		gl.BindTexture(gl.TEXTURE_2D, channel.texture())
+		gl.Uniform1i(p.uniforms.iChannel[i], int32(i))
+		width, height := channel.size()
+		channelResolution[i*3], channelResolution[i*3+1], channelResolution[i*3+2] = float32(width), float32(height), 1
+	}
+	gl.Uniform3fv(p.uniforms.iChannelResolution, channelCount, &channelResolution[0])
+
+	gl.BindVertexArray(vao)
+	gl.DrawArrays(gl.TRIANGLE_STRIP, 0, 4)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	p.target.swap()
+}
+
+// newPassGraph builds the buffer passes and the image pass, and loads the
+// image files bound to their channels. Passes are returned in render order,
+// with the image pass last.
+func newPassGraph(flags *flags, renderWidth, renderHeight int) ([]*renderPass, []*imageTexture, error) {
+	options := shaderOptions{shadertoy: flags.Shadertoy(), tiled: flags.Poster() != "", includeDirs: flags.IncludeDirs()}
+	var passes []*renderPass
+	byName := map[string]*renderPass{}
+	buffers := flags.Buffers()
+	for i, name := range bufferNames {
+		if buffers[i] == "" {
+			continue
+		}
+		// Buffers hold arbitrary simulation state, so they are float textures
+		target := newRenderTarget(renderWidth, renderHeight, gl.RGBA32F, gl.FLOAT, gl.LINEAR)
+		pass, err := newRenderPass(name, buffers[i], target, options)
+		if err != nil {
+			return nil, nil, err
+		}
+		passes = append(passes, pass)
+		byName[name] = pass
+	}
+	image, err := newRenderPass(imagePassName, flags.Frag(), newRenderTarget(renderWidth, renderHeight, gl.RGBA, gl.UNSIGNED_BYTE, gl.NEAREST), options)
+	if err != nil {
+		return nil, nil, err
+	}
+	passes = append(passes, image)
+	byName[imagePassName] = image
+
+	var textures []*imageTexture
+	for _, binding := range flags.Channels() {
+		if binding.source != "" {
+			byName[binding.pass].channels[binding.channel] = byName[binding.source].target
+			continue
+		}
+		texture, err := loadImageTexture(binding.image, binding.options)
+		if err != nil {
+			return nil, nil, err
+		}
+		textures = append(textures, texture)
+		byName[binding.pass].channels[binding.channel] = texture
+	}
+	return passes, textures, nil
+}
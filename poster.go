@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// tileTransform maps the gl_FragCoord of a tile onto the full poster, which
+// is also the iResolution shaders see.
+type tileTransform struct {
+	offsetX, offsetY float32
+	scale            float32
+	width, height    int
+}
+
+// injectTileTransform redefines gl_FragCoord right after the #version
+// directive so that it is offset and scaled by the iTile uniform. #line
+// directives keep line numbers in compile errors matching the original, and
+// tell errors in the generated lines apart.
+func injectTileTransform(source string) string {
+	transform := generatedLineDirective + "uniform vec3 iTile;\n" +
+		"#define gl_FragCoord vec4(gl_FragCoord.xy * iTile.z + iTile.xy, gl_FragCoord.zw)\n"
+
+	match := versionDirectivePattern.FindStringIndex(source)
+	if match == nil {
+		return transform + "#line 1 0\n" + source
+	}
+	end := match[1]
+	if end < len(source) && source[end] == '\n' {
+		end++
+	}
+	line := strings.Count(source[:end], "\n") + 1
+	return source[:end] + transform + "#line " + strconv.Itoa(line) + " 0\n" + source[end:]
+}
+
+// renderPoster renders the image pass into a single PNG larger than any
+// render target the driver supports, by rendering it in tiles. With
+// supersampling each tile is rendered at a multiple of the output resolution
+// and box filtered down, while shaders still see the output resolution.
+func renderPoster(flags *flags, pass *renderPass, params *paramSet, vao uint32) error {
+	width := flags.PosterWidth()
+	height := int(1. / flags.Ar() * float64(width))
+	supersample := flags.Supersample()
+	// Tiles cover a whole number of output pixels
+	tileSize := flags.TileSize() / supersample * supersample
+	tilePixels := tileSize / supersample
+
+	var maxTextureSize int32
+	gl.GetIntegerv(gl.MAX_TEXTURE_SIZE, &maxTextureSize)
+	if tileSize > int(maxTextureSize) {
+		return fmt.Errorf("error: Tile size %d exceeds the maximum texture size of %d", tileSize, maxTextureSize)
+	}
+
+	pass.target.delete()
+	pass.target = newRenderTarget(tileSize, tileSize, gl.RGBA8, gl.UNSIGNED_BYTE, gl.NEAREST)
+	defer pass.target.delete()
+
+	cam := newCamera(flags.Fov())
+	state := cam.frameState()
+	state.time = float32(wrapTime(flags.Start(), flags.Loop()))
+	state.date = shadertoyDate(renderEpoch)
+	state.params = params.values
+	state.tile = &tileTransform{scale: 1 / float32(supersample), width: width, height: height}
+	poster := image.NewNRGBA(image.Rect(0, 0, width, height))
+	columns := (width + tilePixels - 1) / tilePixels
+	rows := (height + tilePixels - 1) / tilePixels
+	for row := range rows {
+		for column := range columns {
+			// Tiles are laid out from the bottom left, like gl_FragCoord
+			x, y := column*tilePixels, row*tilePixels
+			state.tile.offsetX, state.tile.offsetY = float32(x), float32(y)
+			pass.render(vao, state)
+			downsampleInto(poster, pass.target.readPixels(), x, height-y-tilePixels, supersample)
+			log.Printf("Rendered tile %d/%d\n", row*columns+column+1, rows*columns)
+		}
+	}
+
+	metadata := frameMetadata(flags.Frag(), width, height, state, cam.Speed)
+	metadata["Supersample"] = strconv.Itoa(supersample)
+	return writePNG(flags.Poster(), poster, metadata, flags.Dpi())
+}
+
+// srgbToLinear decodes 8-bit sRGB values, so that supersamples are averaged
+// in linear light.
+var srgbToLinear = func() (table [256]float64) {
+	for i := range table {
+		c := float64(i) / 255
+		if c <= 0.04045 {
+			table[i] = c / 12.92
+		} else {
+			table[i] = math.Pow((c+0.055)/1.055, 2.4)
+		}
+	}
+	return table
+}()
+
+func linearToSRGB(c float64) uint8 {
+	if c <= 0.0031308 {
+		c *= 12.92
+	} else {
+		c = 1.055*math.Pow(c, 1/2.4) - 0.055
+	}
+	return uint8(math.Round(max(0, min(1, c)) * 255))
+}
+
+// downsampleInto box filters tile by factor and copies the result into dst
+// with its top left corner at (x, y), clipping whatever falls outside dst.
+func downsampleInto(dst, tile *image.NRGBA, x, y, factor int) {
+	bounds := tile.Bounds()
+	samples := float64(factor * factor)
+	for row := range bounds.Dy() / factor {
+		for column := range bounds.Dx() / factor {
+			if !(image.Point{x + column, y + row}).In(dst.Bounds()) {
+				continue
+			}
+			offset := dst.PixOffset(x+column, y+row)
+			if factor == 1 {
+				copy(dst.Pix[offset:offset+4], tile.Pix[tile.PixOffset(column, row):])
+				continue
+			}
+			var r, g, b float64
+			for sy := row * factor; sy < (row+1)*factor; sy++ {
+				for sx := column * factor; sx < (column+1)*factor; sx++ {
+					i := tile.PixOffset(sx, sy)
+					r += srgbToLinear[tile.Pix[i]]
+					g += srgbToLinear[tile.Pix[i+1]]
+					b += srgbToLinear[tile.Pix[i+2]]
+				}
+			}
+			dst.Pix[offset] = linearToSRGB(r / samples)
+			dst.Pix[offset+1] = linearToSRGB(g / samples)
+			dst.Pix[offset+2] = linearToSRGB(b / samples)
+			dst.Pix[offset+3] = 255
+		}
+	}
+}
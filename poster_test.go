@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestInjectTileTransformLocations(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		marker string
+		number int
+		line   int
+	}{
+		{"generated", "// comment\n#version 460 core\nout vec4 color;\n", "uniform vec3 iTile", generatedSourceNumber, 1},
+		{"after the version", "// comment\n#version 460 core\nout vec4 color;\n", "out vec4 color", 0, 3},
+		{"no version", "out vec4 color;\nvoid main() {}\n", "void main", 0, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			number, line := compileLocation(t, injectTileTransform(test.source), test.marker)
+			if number != test.number || line != test.line {
+				t.Errorf("%q at %d:%d, want %d:%d", test.marker, number, line, test.number, test.line)
+			}
+		})
+	}
+}
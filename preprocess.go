@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	includePattern    = regexp.MustCompile(`^[ \t]*#[ \t]*include[ \t]+(?:"([^"]+)"|<([^>]+)>)[ \t\r]*$`)
+	pragmaOncePattern = regexp.MustCompile(`^[ \t]*#[ \t]*pragma[ \t]+once[ \t\r]*$`)
+)
+
+// lineDirective makes the next line of source line line of source string
+// number.
+func lineDirective(number, line int) string {
+	return fmt.Sprintf("#line %d %d", line, number)
+}
+
+// shaderSource is a fragment shader with its includes resolved. files are
+// the paths it was read from, the shader itself first, each numbered by its
+// index in the #line directives of text. texts are their contents.
+type shaderSource struct {
+	text  string
+	files []string
+	texts []string
+}
+
+// preprocessor resolves #include directives. Each file is included once at
+// most, so files need no include guards of their own, and #pragma once is
+// accepted for compatibility. A file including itself, directly or not, is
+// an error. The #version directive, wherever it is found, is moved to the
+// top as GLSL requires, and #line directives keep compile errors pointing at
+// the file and line they come from.
+type preprocessor struct {
+	includeDirs []string
+	files       []string
+	texts       []string
+	// stack is the chain of files being included, to detect cycles
+	stack   []string
+	version string
+	text    strings.Builder
+}
+
+// preprocessShader reads the shader at path and resolves its includes,
+// relative to the file including them and then to includeDirs. Includes in
+// angle brackets are only looked up in includeDirs. On error, the returned
+// source still lists the files read so far, so that they can be watched for
+// a fix.
+func preprocessShader(path string, includeDirs []string) (shaderSource, error) {
+	p := &preprocessor{includeDirs: includeDirs}
+	err := p.include(filepath.Clean(path))
+	if err != nil {
+		return shaderSource{files: p.files, texts: p.texts}, err
+	}
+	text := p.text.String()
+	if p.version != "" {
+		text = p.version + "\n" + lineDirective(0, 1) + "\n" + text
+	}
+	return shaderSource{text: text + "\x00", files: p.files, texts: p.texts}, nil
+}
+
+func (p *preprocessor) include(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read shader file: %w", err)
+	}
+	number := len(p.files)
+	p.files = append(p.files, path)
+	p.texts = append(p.texts, string(data))
+	p.stack = append(p.stack, path)
+
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		if i > 0 {
+			p.text.WriteString("\n")
+		}
+		switch {
+		case includePattern.MatchString(line):
+			match := includePattern.FindStringSubmatch(line)
+			included, err := p.resolve(path, match[1], match[2])
+			if err != nil {
+				return fmt.Errorf("error: %s:%d: %s", path, i+1, err.Error())
+			}
+			if slices.Contains(p.stack, included) {
+				return fmt.Errorf("error: %s:%d: Include cycle %s -> %s", path, i+1, strings.Join(p.stack, " -> "), included)
+			}
+			// Files already included leave a blank line
+			if slices.Contains(p.files, included) {
+				continue
+			}
+			p.text.WriteString(lineDirective(len(p.files), 1) + "\n")
+			if err := p.include(included); err != nil {
+				return err
+			}
+			p.text.WriteString("\n" + lineDirective(number, i+2))
+		case pragmaOncePattern.MatchString(line):
+		case versionPattern.MatchString(line):
+			if p.version == "" {
+				p.version = strings.TrimSpace(line)
+			}
+		default:
+			p.text.WriteString(line)
+		}
+	}
+
+	p.stack = p.stack[:len(p.stack)-1]
+	return nil
+}
+
+// resolve finds the file an include refers to, quoted or in angle brackets.
+func (p *preprocessor) resolve(from, quoted, bracketed string) (string, error) {
+	name := quoted
+	var candidates []string
+	if quoted != "" {
+		candidates = append(candidates, filepath.Join(filepath.Dir(from), quoted))
+	} else {
+		name = bracketed
+	}
+	for _, dir := range p.includeDirs {
+		candidates = append(candidates, filepath.Join(dir, name))
+	}
+	for _, candidate := range candidates {
+		if ok, _ := exists(candidate); ok {
+			return filepath.Clean(candidate), nil
+		}
+	}
+	return "", fmt.Errorf("Included file %q not found", name)
+}
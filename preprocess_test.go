@@ -0,0 +1,308 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// writeShaderFiles writes files, named by their path relative to a temporary
+// directory, and returns the directory.
+func writeShaderFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, text := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestPreprocessShader(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		includeDirs []string
+		want        []string
+		wantFiles   []string
+		wantErr     string
+	}{
+		{
+			name: "no includes",
+			files: map[string]string{
+				"main.frag": "void main() {}",
+			},
+			want:      []string{"void main() {}"},
+			wantFiles: []string{"main.frag"},
+		},
+		{
+			name: "nested includes",
+			files: map[string]string{
+				"main.frag":    "#include \"lib/a.glsl\"\nvoid main() {}",
+				"lib/a.glsl":   "#include \"b.glsl\"\nfloat a;",
+				"lib/b.glsl":   "float b;",
+				"other/b.glsl": "float other;",
+			},
+			want: []string{
+				"#line 1 1",
+				"#line 1 2",
+				"float b;",
+				"#line 2 1",
+				"float a;",
+				"#line 2 0",
+				"void main() {}",
+			},
+			wantFiles: []string{"main.frag", "lib/a.glsl", "lib/b.glsl"},
+		},
+		{
+			name: "version hoisted from the shader",
+			files: map[string]string{
+				"main.frag": "// comment\n#version 460 core\nvoid main() {}",
+			},
+			want: []string{
+				"#version 460 core",
+				"#line 1 0",
+				"// comment",
+				"",
+				"void main() {}",
+			},
+			wantFiles: []string{"main.frag"},
+		},
+		{
+			name: "version hoisted from an include",
+			files: map[string]string{
+				"main.frag":   "#include \"common.glsl\"\nvoid main() {}",
+				"common.glsl": "  #version 330\nfloat a;",
+			},
+			want: []string{
+				"#version 330",
+				"#line 1 0",
+				"#line 1 1",
+				"",
+				"float a;",
+				"#line 2 0",
+				"void main() {}",
+			},
+			wantFiles: []string{"main.frag", "common.glsl"},
+		},
+		{
+			name: "first version wins",
+			files: map[string]string{
+				"main.frag":   "#version 460 core\n#include \"common.glsl\"",
+				"common.glsl": "#version 330\nfloat a;",
+			},
+			want: []string{
+				"#version 460 core",
+				"#line 1 0",
+				"",
+				"#line 1 1",
+				"",
+				"float a;",
+				"#line 3 0",
+			},
+			wantFiles: []string{"main.frag", "common.glsl"},
+		},
+		{
+			name: "files included once",
+			files: map[string]string{
+				"main.frag": "#include \"a.glsl\"\n#include \"b.glsl\"\n#include \"a.glsl\"\nvoid main() {}",
+				"a.glsl":    "#pragma once\nfloat a;",
+				"b.glsl":    "#include \"a.glsl\"\nfloat b;",
+			},
+			want: []string{
+				"#line 1 1",
+				"",
+				"float a;",
+				"#line 2 0",
+				"#line 1 2",
+				"",
+				"float b;",
+				"#line 3 0",
+				"",
+				"void main() {}",
+			},
+			wantFiles: []string{"main.frag", "a.glsl", "b.glsl"},
+		},
+		{
+			name: "include path",
+			files: map[string]string{
+				"shaders/main.frag": "#include <common.glsl>\n#include \"util.glsl\"",
+				"inc/common.glsl":   "float common;",
+				"inc/util.glsl":     "float util;",
+			},
+			includeDirs: []string{"inc"},
+			want: []string{
+				"#line 1 1",
+				"float common;",
+				"#line 2 0",
+				"#line 1 2",
+				"float util;",
+				"#line 3 0",
+			},
+			wantFiles: []string{"shaders/main.frag", "inc/common.glsl", "inc/util.glsl"},
+		},
+		{
+			name: "quoted includes prefer the including directory",
+			files: map[string]string{
+				"shaders/main.frag":   "#include \"common.glsl\"",
+				"shaders/common.glsl": "float local;",
+				"inc/common.glsl":     "float shared;",
+			},
+			includeDirs: []string{"inc"},
+			want: []string{
+				"#line 1 1",
+				"float local;",
+				"#line 2 0",
+			},
+			wantFiles: []string{"shaders/main.frag", "shaders/common.glsl"},
+		},
+		{
+			name: "bracketed includes skip the including directory",
+			files: map[string]string{
+				"shaders/main.frag":   "float a;\n#include <common.glsl>",
+				"shaders/common.glsl": "float local;",
+			},
+			wantErr:   "error: shaders/main.frag:2: Included file \"common.glsl\" not found",
+			wantFiles: []string{"shaders/main.frag"},
+		},
+		{
+			name: "unresolved include",
+			files: map[string]string{
+				"main.frag":  "#include \"a.glsl\"",
+				"a.glsl":     "float a;\n\n#include <missing.glsl>",
+				"inc/b.glsl": "float b;",
+			},
+			includeDirs: []string{"inc"},
+			wantErr:     "error: a.glsl:3: Included file \"missing.glsl\" not found",
+			wantFiles:   []string{"main.frag", "a.glsl"},
+		},
+		{
+			name: "include cycle",
+			files: map[string]string{
+				"main.frag": "#include \"a.glsl\"",
+				"a.glsl":    "#include \"b.glsl\"",
+				"b.glsl":    "float b;\n#include \"a.glsl\"",
+			},
+			wantErr:   "error: b.glsl:2: Include cycle main.frag -> a.glsl -> b.glsl -> a.glsl",
+			wantFiles: []string{"main.frag", "a.glsl", "b.glsl"},
+		},
+		{
+			name: "shader including itself",
+			files: map[string]string{
+				"main.frag": "#include \"main.frag\"",
+			},
+			wantErr:   "error: main.frag:1: Include cycle main.frag -> main.frag",
+			wantFiles: []string{"main.frag"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeShaderFiles(t, test.files)
+			var includeDirs []string
+			for _, includeDir := range test.includeDirs {
+				includeDirs = append(includeDirs, filepath.Join(dir, includeDir))
+			}
+			path := filepath.Join(dir, test.wantFiles[0])
+			source, err := preprocessShader(path, includeDirs)
+
+			var files []string
+			for _, file := range source.files {
+				relative, _ := filepath.Rel(dir, file)
+				files = append(files, filepath.ToSlash(relative))
+			}
+			if !slices.Equal(files, test.wantFiles) {
+				t.Errorf("files = %q, want %q", files, test.wantFiles)
+			}
+			if test.wantErr != "" {
+				if err == nil {
+					t.Fatalf("error = nil, want %q", test.wantErr)
+				}
+				if got := strings.ReplaceAll(err.Error(), dir+string(filepath.Separator), ""); got != test.wantErr {
+					t.Errorf("error = %q, want %q", got, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if want := strings.Join(test.want, "\n") + "\x00"; source.text != want {
+				t.Errorf("text = %q, want %q", source.text, want)
+			}
+		})
+	}
+}
+
+// compileLocation returns the source string number and line number a
+// compiler reports for the first line of text containing marker, following
+// its #line directives.
+func compileLocation(t *testing.T, text, marker string) (int, int) {
+	t.Helper()
+	number, line := 0, 0
+	for _, l := range strings.Split(text, "\n") {
+		line++
+		if fields := strings.Fields(l); len(fields) == 3 && fields[0] == "#line" {
+			next, err := strconv.Atoi(fields[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if number, err = strconv.Atoi(fields[2]); err != nil {
+				t.Fatal(err)
+			}
+			line = next - 1
+			continue
+		}
+		if strings.Contains(l, marker) {
+			return number, line
+		}
+	}
+	t.Fatalf("%q not found in %q", marker, text)
+	return 0, 0
+}
+
+// TestPreprocessedErrorLocations checks that compile errors point at the file
+// and line the code comes from.
+func TestPreprocessedErrorLocations(t *testing.T) {
+	dir := writeShaderFiles(t, map[string]string{
+		"main.frag":      "#version 460 core\nout vec4 color;\n#include \"lib/noise.glsl\"\nvoid main() {\n\tcolor = vec4(beforeError);\n\tcolor = vec4(afterError);\n}",
+		"lib/noise.glsl": "// Noise\n#include \"hash.glsl\"\nfloat noise(vec2 p) {\n\treturn noiseError;\n}",
+		"lib/hash.glsl":  "float hash(float x) {\n  return hashError;\n}",
+	})
+	source, err := preprocessShader(filepath.Join(dir, "main.frag"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		marker string
+		file   string
+		line   int
+		source string
+	}{
+		{"beforeError", "main.frag", 5, "\tcolor = vec4(beforeError);"},
+		{"afterError", "main.frag", 6, "\tcolor = vec4(afterError);"},
+		{"noiseError", "lib/noise.glsl", 4, "\treturn noiseError;"},
+		{"hashError", "lib/hash.glsl", 2, "  return hashError;"},
+	}
+	for _, test := range tests {
+		t.Run(test.marker, func(t *testing.T) {
+			number, line := compileLocation(t, source.text, test.marker)
+			log := fmt.Sprintf("%d:%d(9): error: `%s' undeclared", number, line, test.marker)
+			diagnostics := parseShaderLog(log, source)
+			if len(diagnostics) != 1 {
+				t.Fatalf("diagnostics = %+v, want 1", diagnostics)
+			}
+			d := diagnostics[0]
+			if want := filepath.Join(dir, test.file); d.file != want || d.line != test.line || d.source != test.source {
+				t.Errorf("diagnostic at %s:%d %q, want %s:%d %q", d.file, d.line, d.source, want, test.line, test.source)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/chewxy/math32"
+)
+
+// quat is a rotation quaternion. Camera orientations rotate the camera's
+// local right (+x), up (+y) and forward (+z) axes into world space.
+type quat struct {
+	w, x, y, z float32
+}
+
+var identityQuat = quat{1, 0, 0, 0}
+
+func quatFromAxisAngle(axis vec3, angle float32) quat {
+	axis = axis.normalize()
+	sin := math32.Sin(angle / 2)
+	return quat{math32.Cos(angle / 2), axis.x * sin, axis.y * sin, axis.z * sin}
+}
+
+// quatFromBasis returns the rotation taking the x, y and z axes to right, up
+// and forward, which must be orthonormal.
+func quatFromBasis(right, up, forward vec3) quat {
+	trace := right.x + up.y + forward.z
+	var q quat
+	switch {
+	case trace > 0:
+		s := 2 * math32.Sqrt(trace+1)
+		q = quat{s / 4, (up.z - forward.y) / s, (forward.x - right.z) / s, (right.y - up.x) / s}
+	case right.x > up.y && right.x > forward.z:
+		s := 2 * math32.Sqrt(1+right.x-up.y-forward.z)
+		q = quat{(up.z - forward.y) / s, s / 4, (up.x + right.y) / s, (forward.x + right.z) / s}
+	case up.y > forward.z:
+		s := 2 * math32.Sqrt(1+up.y-right.x-forward.z)
+		q = quat{(forward.x - right.z) / s, (up.x + right.y) / s, s / 4, (forward.y + up.z) / s}
+	default:
+		s := 2 * math32.Sqrt(1+forward.z-right.x-up.y)
+		q = quat{(right.y - up.x) / s, (forward.x + right.z) / s, (forward.y + up.z) / s, s / 4}
+	}
+	return q.normalize()
+}
+
+// mul returns the rotation q followed by r in q's local frame.
+func (q quat) mul(r quat) quat {
+	return quat{
+		q.w*r.w - q.x*r.x - q.y*r.y - q.z*r.z,
+		q.w*r.x + q.x*r.w + q.y*r.z - q.z*r.y,
+		q.w*r.y - q.x*r.z + q.y*r.w + q.z*r.x,
+		q.w*r.z + q.x*r.y - q.y*r.x + q.z*r.w,
+	}
+}
+
+func (q quat) dot(r quat) float32 {
+	return q.w*r.w + q.x*r.x + q.y*r.y + q.z*r.z
+}
+
+func (q quat) normalize() quat {
+	l := math32.Sqrt(q.dot(q))
+	return quat{q.w / l, q.x / l, q.y / l, q.z / l}
+}
+
+func (q quat) rotate(v vec3) vec3 {
+	axis := vec3{q.x, q.y, q.z}
+	t := axis.cross(v).scale(2)
+	return v.add(t.scale(q.w)).add(axis.cross(t))
+}
+
+// slerp interpolates between the unit quaternions q and r along the shortest
+// arc.
+func (q quat) slerp(r quat, t float32) quat {
+	cos := q.dot(r)
+	if cos < 0 {
+		r, cos = quat{-r.w, -r.x, -r.y, -r.z}, -cos
+	}
+	if cos > 0.9995 {
+		return quat{
+			q.w + (r.w-q.w)*t,
+			q.x + (r.x-q.x)*t,
+			q.y + (r.y-q.y)*t,
+			q.z + (r.z-q.z)*t,
+		}.normalize()
+	}
+	angle := math32.Acos(cos)
+	sin := math32.Sin(angle)
+	a, b := math32.Sin((1-t)*angle)/sin, math32.Sin(t*angle)/sin
+	return quat{q.w*a + r.w*b, q.x*a + r.x*b, q.y*a + r.y*b, q.z*a + r.z*b}
+}
+
+func (q quat) MarshalJSON() ([]byte, error) {
+	return json.Marshal([4]float32{q.w, q.x, q.y, q.z})
+}
+
+func (q *quat) UnmarshalJSON(data []byte) error {
+	var components [4]float32
+	if err := json.Unmarshal(data, &components); err != nil {
+		return err
+	}
+	q.w, q.x, q.y, q.z = components[0], components[1], components[2], components[3]
+	return nil
+}
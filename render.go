@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// renderEpoch is the wall clock time iDate starts from in offline renders, so
+// renders do not depend on when they were made.
+var renderEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// renderSequence renders the pass graph offline with a fixed timestep and
+// writes every frame of the image pass as a numbered PNG file in the output
+// directory.
+func renderSequence(flags *flags, passes []*renderPass, params *paramSet, vao uint32) error {
+	if err := os.MkdirAll(flags.Render(), 0o755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	// Without a camera path the camera stays at its starting state
+	var flight *cameraPath
+	if flags.CameraPath() != "" {
+		var err error
+		if flight, err = loadCameraPath(flags.CameraPath(), true); err != nil {
+			return err
+		}
+	}
+
+	// A zero duration renders a single still at the start time
+	frames := max(1, int(math.Round(flags.Duration()*flags.Fps())))
+	timeDelta := 1 / flags.Fps()
+	image := passes[len(passes)-1]
+	for frame := range frames {
+		seconds := flags.Start() + float64(frame)*timeDelta
+		cam := newCamera(flags.Fov())
+		if flight != nil {
+			cam = flight.at(seconds - flags.Start())
+		}
+		state := cam.frameState()
+		state.time = float32(wrapTime(seconds, flags.Loop()))
+		state.timeDelta = float32(timeDelta)
+		state.frameRate = float32(flags.Fps())
+		state.frame = frame
+		state.date = shadertoyDate(renderEpoch.Add(time.Duration(seconds * float64(time.Second))))
+		state.params = params.values
+		for _, pass := range passes {
+			pass.render(vao, state)
+		}
+
+		path := filepath.Join(flags.Render(), fmt.Sprintf("frame_%05d.png", frame))
+		metadata := frameMetadata(flags.Frag(), image.target.width, image.target.height, state, cam.Speed)
+		if err := writePNG(path, image.target.readPixels(), metadata, 0); err != nil {
+			return err
+		}
+		log.Printf("Rendered %s (%d/%d)\n", path, frame+1, frames)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import "math"
+
+// Adaptive resolution changes the render size in steps of resolutionStep of
+// its width and height, down to minRenderScale of the size it started at.
+const (
+	resolutionStep = 0.9
+	minRenderScale = 0.25
+	// resolutionSamples is how many frames are measured at a render size
+	// before deciding whether to change it
+	resolutionSamples = 30
+	// resolutionHeadroom is the fraction of the frame time budget a larger
+	// render size must be predicted to fit in, so that the size does not
+	// oscillate between two steps
+	resolutionHeadroom = 0.8
+)
+
+// resolutionScaler picks the render size at which the GPU time the passes
+// take fits in the frame time of a target frame rate. The CPU time and the
+// time spent waiting for vsync are not measured, as the render size does not
+// change them.
+type resolutionScaler struct {
+	width, height int
+	// budget is the frame time to hold, in milliseconds
+	budget float64
+	steps  int
+	// step is how many steps the render size currently is below its full size
+	step    int
+	samples []float64
+	// since is the first frame rendered at the current size. Timings are read
+	// a few frames late, so some still belong to frames at the previous one
+	since int
+}
+
+func newResolutionScaler(width, height int, targetFps float64) *resolutionScaler {
+	return &resolutionScaler{
+		width:  width,
+		height: height,
+		budget: 1000 / targetFps,
+		steps:  int(math.Floor(math.Log(minRenderScale) / math.Log(resolutionStep))),
+	}
+}
+
+// size returns the render size at the current step.
+func (s *resolutionScaler) size() (int, int) {
+	scale := math.Pow(resolutionStep, float64(s.step))
+	return max(1, int(math.Round(float64(s.width)*scale))), max(1, int(math.Round(float64(s.height)*scale)))
+}
+
+// update adds the timing of a frame, and returns whether the render size
+// changes from the next frame on. The size goes down as soon as frames go over
+// budget, but only goes up a step once the frames at the larger size, whose
+// cost grows with their pixel count, are predicted to fit with some headroom.
+func (s *resolutionScaler) update(timing frameTiming, next int) bool {
+	if timing.frame < s.since {
+		return false
+	}
+	s.samples = append(s.samples, timing.passes)
+	if len(s.samples) < resolutionSamples {
+		return false
+	}
+	average := summarizeTimings(s.samples).Avg
+	s.samples = s.samples[:0]
+
+	step := s.step
+	if average > s.budget {
+		// Go down as many steps as it takes to fit at once
+		step = min(s.steps, s.step+max(1, int(math.Ceil(math.Log(s.budget/average)/(2*math.Log(resolutionStep))))))
+	} else if s.step > 0 && average/(resolutionStep*resolutionStep) < s.budget*resolutionHeadroom {
+		step--
+	}
+	if step == s.step {
+		return false
+	}
+	s.step = step
+	s.since = next
+	return true
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// frameMetadata describes the state a frame was rendered with, so that it can
+// be reproduced later. It is stored as PNG text chunks.
+func frameMetadata(shaderPath string, width, height int, state frameState, speed float32) map[string]string {
+	metadata := map[string]string{
+		"Software":       "gigashad",
+		"Shader":         shaderPath,
+		"Resolution":     fmt.Sprintf("%dx%d", width, height),
+		"iTime":          formatFloats(state.time),
+		"iPosition":      formatFloats(state.position.x, state.position.y, state.position.z),
+		"iPositionFixed": formatFloats(state.positionFixed.x, state.positionFixed.y, state.positionFixed.z),
+		"iDirection":     formatFloats(state.direction.x, state.direction.y, state.direction.z),
+		"speed":          formatFloats(speed),
+		"iCameraRight":   formatFloats(state.right.x, state.right.y, state.right.z),
+		"iCameraUp":      formatFloats(state.up.x, state.up.y, state.up.z),
+		"iFov":           formatFloats(state.fov),
+		"iSliders":       formatFloats(state.sliders[:]...),
+	}
+	if len(state.params) > 0 {
+		params := make([]string, 0, len(state.params))
+		for name, value := range state.params {
+			params = append(params, name+"="+formatFloats(value))
+		}
+		sort.Strings(params)
+		metadata["Params"] = strings.Join(params, " ")
+	}
+	return metadata
+}
+
+// formatFloats formats values with the fewest digits that still parse back
+// to exactly the same float32s.
+func formatFloats(values ...float32) string {
+	formatted := make([]string, len(values))
+	for i, value := range values {
+		formatted[i] = strconv.FormatFloat(float64(value), 'g', -1, 32)
+	}
+	return strings.Join(formatted, " ")
+}
+
+// saveScreenshot writes the image pass output at render resolution into dir,
+// named after the shader and the current time. It returns the file path.
+func saveScreenshot(dir, shaderPath string, target *renderTarget, metadata map[string]string) (string, error) {
+	name := strings.TrimSuffix(filepath.Base(shaderPath), filepath.Ext(shaderPath))
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.png", name, time.Now().Format("20060102-150405.000")))
+	if err := writePNG(path, target.readPixels(), metadata, 0); err != nil {
+		return "", err
+	}
+	return path, nil
+}
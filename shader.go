@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+const quadVertexShaderSource = `
+	#version 460 core
+	layout(location = 0) in vec2 pos;
+	void main() {
+		gl_Position = vec4(pos, 0.0, 1.0);
+	}
+	` + "\x00"
+
+// buildShader compiles and links a program. Sources declaring a newer GLSL
+// version than the context supports are compiled as the newest supported
+// one, which works as long as they don't rely on the missing features.
+func buildShader(vertexShaderSource, fragmentShaderSource string) (uint32, error) {
+	context := currentGLVersion()
+	vertexShaderSource, _ = matchContextVersion(vertexShaderSource, context)
+	fragmentShaderSource, declared := matchContextVersion(fragmentShaderSource, context)
+
+	vertex := gl.CreateShader(gl.VERTEX_SHADER)
+	cvs, freeVertex := gl.Strs(vertexShaderSource)
+	gl.ShaderSource(vertex, 1, cvs, nil)
+	freeVertex()
+	gl.CompileShader(vertex)
+	defer gl.DeleteShader(vertex)
+	if err := checkShaderCompileErrors(vertex, "VERTEX"); err != nil {
+		return 0, err
+	}
+
+	fragment := gl.CreateShader(gl.FRAGMENT_SHADER)
+	cfs, freeFragment := gl.Strs(fragmentShaderSource)
+	gl.ShaderSource(fragment, 1, cfs, nil)
+	freeFragment()
+	gl.CompileShader(fragment)
+	defer gl.DeleteShader(fragment)
+	if err := checkShaderCompileErrors(fragment, "FRAGMENT"); err != nil {
+		if declared != 0 {
+			err.note = fmt.Sprintf("shader requires GLSL %d (OpenGL %s) but the context only supports OpenGL %s, compiling it as GLSL %d failed:",
+				declared, glVersionFromGLSL(declared), context, context.glsl())
+		}
+		return 0, err
+	}
+
+	program := gl.CreateProgram()
+	gl.AttachShader(program, vertex)
+	gl.AttachShader(program, fragment)
+	gl.LinkProgram(program)
+	if err := checkProgramLinkErrors(program); err != nil {
+		gl.DeleteProgram(program)
+		return 0, err
+	}
+
+	return program, nil
+}
+
+// checkShaderCompileErrors returns the compile log of shader if it failed to
+// compile.
+func checkShaderCompileErrors(shader uint32, shaderType string) *shaderError {
+	var status int32
+	gl.GetShaderiv(shader, gl.COMPILE_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetShaderiv(shader, gl.INFO_LOG_LENGTH, &logLength)
+		logMsg := strings.Repeat("\x00", int(logLength+1))
+		gl.GetShaderInfoLog(shader, logLength, nil, gl.Str(logMsg))
+		return &shaderError{stage: shaderType + " SHADER COMPILE", log: strings.TrimSpace(strings.TrimRight(logMsg, "\x00"))}
+	}
+	return nil
+}
+
+func checkProgramLinkErrors(program uint32) *shaderError {
+	var status int32
+	gl.GetProgramiv(program, gl.LINK_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetProgramiv(program, gl.INFO_LOG_LENGTH, &logLength)
+		logMsg := strings.Repeat("\x00", int(logLength+1))
+		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(logMsg))
+		return &shaderError{stage: "PROGRAM LINK", log: strings.TrimSpace(strings.TrimRight(logMsg, "\x00"))}
+	}
+	return nil
+}
+
+// shaderOptions control how user shader sources are transformed before
+// being compiled.
+type shaderOptions struct {
+	// shadertoy forces wrapping the source as a Shadertoy shader, otherwise
+	// it is only wrapped when detected as one
+	shadertoy bool
+	// tiled remaps gl_FragCoord through the iTile uniform, for rendering an
+	// image larger than a single render target in tiles
+	tiled bool
+	// includeDirs are searched for included files not found next to the file
+	// including them
+	includeDirs []string
+}
+
+// loadShaderProgram reads the fragment shader at path, resolving its
+// includes, and links it with the fullscreen quad vertex shader. Shadertoy
+// shaders are wrapped first, either when forced or when the source is
+// detected as one. It also returns the parameters the shader declares, and
+// the files it was read from, which are returned even if it failed to build.
+func loadShaderProgram(path string, options shaderOptions) (uint32, []shaderParam, []string, error) {
+	source, err := preprocessShader(path, options.includeDirs)
+	if err != nil {
+		return 0, nil, source.files, err
+	}
+	fragmentShaderSource := source.text
+	params, err := parseShaderParams(fragmentShaderSource)
+	if err != nil {
+		return 0, nil, source.files, fmt.Errorf("error: Parameters of %s could not be parsed:\n\t%s", path, err.Error())
+	}
+	if options.shadertoy || isShadertoySource(fragmentShaderSource) {
+		fragmentShaderSource = wrapShadertoySource(fragmentShaderSource)
+	}
+	if options.tiled {
+		fragmentShaderSource = injectTileTransform(fragmentShaderSource)
+	}
+	program, err := buildShader(quadVertexShaderSource, fragmentShaderSource)
+	var shaderErr *shaderError
+	if errors.As(err, &shaderErr) {
+		shaderErr.diagnostics = parseShaderLog(shaderErr.log, source)
+	}
+	if err != nil {
+		return 0, nil, source.files, err
+	}
+	return program, params, source.files, nil
+}
@@ -7,7 +7,9 @@ uniform vec2 iResolution;
 uniform vec3 iPosition;
 uniform vec3 iPositionFixed;
 uniform vec3 iDirection;
-uniform vec4 iSliders;
+uniform mat3 iView;
+uniform float iFov;
+uniform float lod; // @param min=0.25 max=4 default=1 step=0.01
 
 vec3 CSize;
 vec4 eStack[2];
@@ -19,13 +21,6 @@ vec2 rotationStore = vec2(1., 0.);
 vec2 mouseStore = vec2(2., 0.);
 vec3 sunLight = vec3(0.4, 0.4, 0.3);
 
-float MapReal2Positive(float value) {
-  if (value <= 0) return 1.0 - exp(value);
-  return 1. + value;
-}
-
-float lod = MapReal2Positive(iSliders.y);
-
 float Hash(vec2 p){
   vec3 p3 = fract(vec3(p.xyx) * vec3(.1031, .11369, .13787));
   p3 += dot(p3, p3.yzx + 19.19);
@@ -110,9 +105,10 @@ vec3 GetNormal(vec3 p, float sphereR) {
   ));
 }
 
+// Larger levels of detail give coarser surfaces, but render faster
 float SphereRadius(in float t) {
   t = t * .01 * (400. / iResolution.y);
-  return (t * t + 0.005);
+  return (t * t + 0.005) * lod;
 }
 
 float Scene(in vec3 rO, in vec3 rD) {
@@ -152,14 +148,6 @@ vec3 Albedo(vec3 pos, vec3 nor) {
   return iDirection/10 + normalize(pos.yzx)/10*Colour(pos.yzx);
 }
 
-mat3 lookAt(vec3 camPos, vec3 target) {
-  vec3 forward = normalize(target - camPos);
-  vec3 right = normalize(cross(vec3(0.0, 1.0, 0.0), forward));
-  vec3 up = cross(forward, right);
-
-  return mat3(right, up, forward);
-}
-
 void main() {
   fcoord = gl_FragCoord.xy;
   float gTime = ((iTime + 26.) * .2);
@@ -169,13 +157,13 @@ void main() {
   CSize = vec3(1., 1., 1.3);
 
   vec3 cameraPos = iPosition*0.05 + vec3(-13.0, 3, 2.5);
-  mat3 camMat = lookAt(cameraPos, cameraPos + iDirection);
+  mat3 camMat = iView;
   vec2 mou = vec2(0, 0);
   mat3 mZ = RotationMatrix(vec3(.0, .0, 1.0), 0.);
   mat3 mX = RotationMatrix(vec3(1.0, .0, .0), mou.y);
   mat3 mY = RotationMatrix(vec3(.0, 1.0, 0.0), -mou.x);
   mX = mY * mX * mZ;
-  vec3 dir = vec3(uv.x, uv.y, 1.2+iSliders.x*0.005);
+  vec3 dir = vec3(uv.x, uv.y, 1./tan(iFov*.5));
   dir = camMat * normalize(dir);
 
   vec3 col = vec3(.0);
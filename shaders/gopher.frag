@@ -7,7 +7,9 @@ uniform vec2 iResolution;
 uniform vec3 iPosition;
 uniform vec3 iPositionFixed;
 uniform vec3 iDirection;
-uniform vec4 iSliders;
+uniform vec3 iCameraRight;
+uniform vec3 iCameraUp;
+uniform float iFov;
 
 float smin(float a, float b, float k) {
   float h = max(k-abs(a-b), 0.0)/k;
@@ -234,10 +236,8 @@ vec3 GetNormal(vec3 p) {
   return normalize(n);
 }
 
-vec3 GetRayDir(vec2 uv, vec3 p, vec3 f, float z) {
-  vec3 r = normalize(cross(vec3(0,1,0),f)),
-  u = cross(f,r),
-  c = f*z,
+vec3 GetRayDir(vec2 uv, vec3 f, vec3 r, vec3 u, float z) {
+  vec3 c = f*z,
   i = c + uv.x*r + uv.y*u,
   d = normalize(i);
   return d;
@@ -291,7 +291,7 @@ void main() {
   vec2 uv = (gl_FragCoord.xy-.5*iResolution.xy)/iResolution.y;
   
   vec3 ro = iPosition*0.05 - vec3(0.0, 0.0, 3.0);
-  vec3 rd = GetRayDir(uv, ro, iDirection, 1.+iSliders.x*0.005);
+  vec3 rd = GetRayDir(uv, iDirection, iCameraRight, iCameraUp, .5/tan(iFov*.5));
 
   vec3 col = vec3(0.4, 0.4, 0.9) * (1.0 - rd.y);
 
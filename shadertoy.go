@@ -0,0 +1,128 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// generatedSourceNumber is the source string number #line directives give
+// code generated around user shaders, so that compile errors in it are not
+// reported at lines of the user's files.
+const generatedSourceNumber = 1000
+
+// generatedLineDirective starts a block of generated code.
+var generatedLineDirective = lineDirective(generatedSourceNumber, 1) + "\n"
+
+// shadertoyPrelude declares the standard Shadertoy inputs, plus the
+// gigashad-specific ones so ported shaders can still use the camera.
+var shadertoyPrelude = "#version 460 core\n" + generatedLineDirective + `uniform vec3 iResolution;
+uniform float iTime;
+uniform float iTimeDelta;
+uniform float iFrameRate;
+uniform int iFrame;
+uniform float iChannelTime[4];
+uniform vec3 iChannelResolution[4];
+uniform vec4 iMouse;
+uniform vec4 iDate;
+uniform float iSampleRate;
+uniform sampler2D iChannel0;
+uniform sampler2D iChannel1;
+uniform sampler2D iChannel2;
+uniform sampler2D iChannel3;
+
+uniform float iSpeed;
+uniform vec3 iPosition;
+uniform vec3 iPositionFixed;
+uniform vec3 iDirection;
+uniform vec3 iCameraRight;
+uniform vec3 iCameraUp;
+uniform mat4 iView;
+uniform float iFov;
+uniform vec4 iSliders;
+
+out vec4 shadertoyFragColor;
+#line 1 0
+`
+
+var shadertoyEpilogue = "\n" + generatedLineDirective + `void main() {
+	shadertoyFragColor = vec4(0.0, 0.0, 0.0, 1.0);
+	mainImage(shadertoyFragColor, gl_FragCoord.xy);
+}
+`
+
+const shadertoySampleRate = 44100
+
+var (
+	mainImagePattern = regexp.MustCompile(`\bvoid\s+mainImage\s*\(`)
+	mainPattern      = regexp.MustCompile(`\bvoid\s+main\s*\(`)
+	versionPattern   = regexp.MustCompile(`(?m)^[ \t]*#version[^\n]*`)
+)
+
+// isShadertoySource reports whether source looks like a Shadertoy shader,
+// meaning it defines mainImage but no main of its own.
+func isShadertoySource(source string) bool {
+	return mainImagePattern.MatchString(source) && !mainPattern.MatchString(source)
+}
+
+// wrapShadertoySource turns a Shadertoy shader into a standalone fragment
+// shader by prepending the uniform declarations and calling mainImage from a
+// generated main. Line numbers in compile errors still match the original.
+func wrapShadertoySource(source string) string {
+	source = strings.TrimSuffix(source, "\x00")
+	// The prelude provides the #version directive, blank out any other one
+	// so line numbers are preserved
+	source = versionPattern.ReplaceAllString(source, "")
+	return shadertoyPrelude + source + shadertoyEpilogue + "\x00"
+}
+
+// shadertoyMouse tracks the left mouse button following Shadertoy's iMouse
+// conventions: xy is the cursor position while dragging, zw is the position
+// of the last click, z is negative once the button is released and w is only
+// positive on the frame the button went down.
+type shadertoyMouse struct {
+	x, y           float32
+	clickX, clickY float32
+	down, clicked  bool
+}
+
+func (m *shadertoyMouse) update(window *glfw.Window, renderWidth, renderHeight int) {
+	pressed := window.GetMouseButton(glfw.MouseButtonLeft) == glfw.Press
+	m.clicked = pressed && !m.down
+	m.down = pressed
+	if !pressed {
+		return
+	}
+
+	// The cursor is disabled for mouse look, so its virtual position is
+	// unbounded; clamp it to the render area
+	xpos, ypos := window.GetCursorPos()
+	windowWidth, windowHeight := window.GetSize()
+	x := float32(xpos / float64(windowWidth) * float64(renderWidth))
+	y := float32(renderHeight) - float32(ypos/float64(windowHeight)*float64(renderHeight))
+	m.x = max(0, min(float32(renderWidth), x))
+	m.y = max(0, min(float32(renderHeight), y))
+	if m.clicked {
+		m.clickX, m.clickY = m.x, m.y
+	}
+}
+
+func (m shadertoyMouse) uniform() [4]float32 {
+	z, w := m.clickX, m.clickY
+	if !m.down {
+		z = -z
+	}
+	if !m.clicked {
+		w = -w
+	}
+	return [4]float32{m.x, m.y, z, w}
+}
+
+// shadertoyDate returns iDate: year, zero-based month, day of the month and
+// seconds since midnight.
+func shadertoyDate(now time.Time) [4]float32 {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return [4]float32{float32(now.Year()), float32(now.Month() - 1), float32(now.Day()), float32(now.Sub(midnight).Seconds())}
+}
@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestWrapShadertoySourceLocations(t *testing.T) {
+	source := wrapShadertoySource("#version 300 es\nvoid mainImage(out vec4 fragColor, in vec2 fragCoord) {\n\tfragColor = vec4(userLine);\n}\x00")
+	tests := []struct {
+		marker string
+		number int
+		line   int
+	}{
+		{"uniform vec3 iResolution", generatedSourceNumber, 1},
+		{"out vec4 shadertoyFragColor", generatedSourceNumber, 26},
+		{"void mainImage", 0, 2},
+		{"userLine", 0, 3},
+		{"mainImage(shadertoyFragColor", generatedSourceNumber, 3},
+	}
+	for _, test := range tests {
+		number, line := compileLocation(t, source, test.marker)
+		if number != test.number || line != test.line {
+			t.Errorf("%q at %d:%d, want %d:%d", test.marker, number, line, test.number, test.line)
+		}
+	}
+}
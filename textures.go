@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+type textureFilter int
+
+const (
+	filterNearest textureFilter = iota
+	filterLinear
+	filterMipmap
+)
+
+var textureFilters = map[string]textureFilter{
+	"nearest": filterNearest,
+	"linear":  filterLinear,
+	"mipmap":  filterMipmap,
+}
+
+type textureWrap int32
+
+const (
+	wrapClamp  textureWrap = gl.CLAMP_TO_EDGE
+	wrapRepeat textureWrap = gl.REPEAT
+	wrapMirror textureWrap = gl.MIRRORED_REPEAT
+)
+
+var textureWraps = map[string]textureWrap{
+	"clamp":  wrapClamp,
+	"repeat": wrapRepeat,
+	"mirror": wrapMirror,
+}
+
+// textureOptions control how an image file is sampled. The defaults match
+// Shadertoy's.
+type textureOptions struct {
+	filter textureFilter
+	wrap   textureWrap
+	vflip  bool
+}
+
+var defaultTextureOptions = textureOptions{filter: filterMipmap, wrap: wrapRepeat, vflip: true}
+
+// imageTexture is a PNG or JPEG file uploaded as a texture, bindable to an
+// iChannel sampler.
+type imageTexture struct {
+	path          string
+	options       textureOptions
+	id            uint32
+	width, height int
+}
+
+func loadImageTexture(path string, options textureOptions) (*imageTexture, error) {
+	t := &imageTexture{path: path, options: options}
+	gl.GenTextures(1, &t.id)
+	if err := t.reload(); err != nil {
+		gl.DeleteTextures(1, &t.id)
+		return nil, err
+	}
+	return t, nil
+}
+
+// reload decodes the image file again and uploads it into the same texture,
+// so passes sampling it pick up the new contents. On error the previous
+// contents are kept.
+func (t *imageTexture) reload() error {
+	file, err := os.Open(t.path)
+	if err != nil {
+		return fmt.Errorf("failed to open texture file: %w", err)
+	}
+	defer file.Close()
+
+	decoded, _, err := image.Decode(file)
+	if err != nil {
+		return fmt.Errorf("failed to decode texture file %s: %w", t.path, err)
+	}
+	bounds := decoded.Bounds()
+	pixels := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(pixels, pixels.Bounds(), decoded, bounds.Min, draw.Src)
+	// Images are stored top row first but OpenGL expects the bottom row first
+	if t.options.vflip {
+		flipRows(pixels.Pix, pixels.Stride, bounds.Dy())
+	}
+
+	t.width, t.height = bounds.Dx(), bounds.Dy()
+	gl.BindTexture(gl.TEXTURE_2D, t.id)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA8, int32(t.width), int32(t.height), 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(pixels.Pix))
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, int32(t.options.wrap))
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, int32(t.options.wrap))
+	switch t.options.filter {
+	case filterNearest:
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	case filterLinear:
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	case filterMipmap:
+		gl.GenerateMipmap(gl.TEXTURE_2D)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
+		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	}
+	return nil
+}
+
+func (t *imageTexture) texture() uint32 {
+	return t.id
+}
+
+func (t *imageTexture) size() (int, int) {
+	return t.width, t.height
+}
+
+// flipRows reverses the order of the rows of a pixel buffer in place.
+func flipRows(pix []uint8, stride, rows int) {
+	row := make([]uint8, stride)
+	for top, bottom := 0, rows-1; top < bottom; top, bottom = top+1, bottom-1 {
+		copy(row, pix[top*stride:(top+1)*stride])
+		copy(pix[top*stride:(top+1)*stride], pix[bottom*stride:(bottom+1)*stride])
+		copy(pix[bottom*stride:(bottom+1)*stride], row)
+	}
+}
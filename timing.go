@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// timerLatency is how many frames the GPU timer queries are read behind the
+// frame being rendered, so that reading them never waits for the GPU.
+const timerLatency = 4
+
+// timingWindow is how many frames the rolling timing statistics cover.
+const timingWindow = 300
+
+// frameTiming is how long a frame took, in milliseconds. interval is the time
+// since the previous frame started, cpu the time spent building the frame
+// before swapping buffers, and passes and blit the GPU time spent rendering
+// the user passes and blitting the image to the window.
+type frameTiming struct {
+	frame    int
+	time     float32
+	interval float64
+	cpu      float64
+	passes   float64
+	blit     float64
+}
+
+// Sections of a frame timed on the GPU
+const (
+	timerPasses = iota
+	timerBlit
+	timerSections
+)
+
+// frameTimer times frames on the GPU with GL_TIME_ELAPSED queries. Each of
+// the last timerLatency frames has its own queries, which are read once
+// their results are available. Sections a frame does not time take no time.
+type frameTimer struct {
+	queries [timerLatency][timerSections]uint32
+	used    [timerLatency][timerSections]bool
+	timings [timerLatency]frameTiming
+	pending [timerLatency]bool
+	// next is the slot of the frame being measured, and also the one of the
+	// oldest frame still pending
+	next int
+	done []frameTiming
+}
+
+func newFrameTimer() *frameTimer {
+	t := &frameTimer{}
+	gl.GenQueries(timerLatency*timerSections, &t.queries[0][0])
+	return t
+}
+
+// begin starts measuring a frame. If the GPU is so far behind that the
+// queries of the slot are still pending, it waits for them.
+func (t *frameTimer) begin(frame int, time float32, interval float64) {
+	if t.pending[t.next] {
+		t.read(t.next)
+	}
+	t.timings[t.next] = frameTiming{frame: frame, time: time, interval: interval * 1000}
+	t.used[t.next] = [timerSections]bool{}
+}
+
+// beginSection and endSection bracket the GL commands of a section of the
+// frame. Sections cannot overlap.
+func (t *frameTimer) beginSection(section int) {
+	gl.BeginQuery(gl.TIME_ELAPSED, t.queries[t.next][section])
+	t.used[t.next][section] = true
+}
+
+func (t *frameTimer) endSection() {
+	gl.EndQuery(gl.TIME_ELAPSED)
+}
+
+// end finishes measuring the frame, with the CPU time it took in seconds.
+func (t *frameTimer) end(cpu float64) {
+	t.timings[t.next].cpu = cpu * 1000
+	t.pending[t.next] = true
+	t.next = (t.next + 1) % timerLatency
+}
+
+// poll returns the timings of the frames whose queries finished since the
+// last call, in frame order.
+func (t *frameTimer) poll() []frameTiming {
+	for i := range timerLatency {
+		slot := (t.next + i) % timerLatency
+		if !t.pending[slot] {
+			continue
+		}
+		if !t.available(slot) {
+			break
+		}
+		t.read(slot)
+	}
+	done := t.done
+	t.done = nil
+	return done
+}
+
+// flush waits for the queries of the frames still pending and returns their
+// timings, in frame order.
+func (t *frameTimer) flush() []frameTiming {
+	for i := range timerLatency {
+		slot := (t.next + i) % timerLatency
+		if t.pending[slot] {
+			t.read(slot)
+		}
+	}
+	done := t.done
+	t.done = nil
+	return done
+}
+
+func (t *frameTimer) available(slot int) bool {
+	for section, used := range t.used[slot] {
+		if used {
+			var available int32
+			gl.GetQueryObjectiv(t.queries[slot][section], gl.QUERY_RESULT_AVAILABLE, &available)
+			if available == gl.FALSE {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (t *frameTimer) read(slot int) {
+	var elapsed [timerSections]uint64
+	for section, used := range t.used[slot] {
+		if used {
+			gl.GetQueryObjectui64v(t.queries[slot][section], gl.QUERY_RESULT, &elapsed[section])
+		}
+	}
+	t.timings[slot].passes = float64(elapsed[timerPasses]) / 1e6
+	t.timings[slot].blit = float64(elapsed[timerBlit]) / 1e6
+	t.pending[slot] = false
+	t.done = append(t.done, t.timings[slot])
+}
+
+// timingSummary describes a series of timings, in milliseconds.
+type timingSummary struct {
+	Min float64 `json:"min"`
+	Avg float64 `json:"avg"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+}
+
+// rollingTimings keeps the last timingWindow values of a timing.
+type rollingTimings struct {
+	values []float64
+	next   int
+}
+
+func (r *rollingTimings) add(value float64) {
+	if len(r.values) < timingWindow {
+		r.values = append(r.values, value)
+		return
+	}
+	r.values[r.next] = value
+	r.next = (r.next + 1) % timingWindow
+}
+
+func (r *rollingTimings) summary() timingSummary {
+	return summarizeTimings(r.values)
+}
+
+// summarizeTimings returns the minimum, average and nearest-rank percentiles
+// of values.
+func summarizeTimings(values []float64) timingSummary {
+	if len(values) == 0 {
+		return timingSummary{}
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	sum := 0.
+	for _, value := range sorted {
+		sum += value
+	}
+	percentile := func(p float64) float64 {
+		rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+		return sorted[max(0, rank-1)]
+	}
+	return timingSummary{
+		Min: sorted[0],
+		Avg: sum / float64(len(sorted)),
+		P95: percentile(95),
+		P99: percentile(99),
+	}
+}
+
+// frameStats keeps rolling statistics of each frame timing.
+type frameStats struct {
+	interval, cpu, passes, blit rollingTimings
+}
+
+func (s *frameStats) add(timing frameTiming) {
+	s.interval.add(timing.interval)
+	s.cpu.add(timing.cpu)
+	s.passes.add(timing.passes)
+	s.blit.add(timing.blit)
+}
+
+// lines describes the statistics for the HUD.
+func (s *frameStats) lines() []string {
+	lines := []string{fmt.Sprintf("%-10s %7s %7s %7s %7s", "ms", "min", "avg", "p95", "p99")}
+	for _, row := range []struct {
+		name    string
+		timings *rollingTimings
+	}{{"frame", &s.interval}, {"cpu", &s.cpu}, {"gpu passes", &s.passes}, {"gpu blit", &s.blit}} {
+		summary := row.timings.summary()
+		lines = append(lines, fmt.Sprintf("%-10s %7.2f %7.2f %7.2f %7.2f", row.name, summary.Min, summary.Avg, summary.P95, summary.P99))
+	}
+	return lines
+}
+
+// timingsCSV streams frame timings to a CSV file, one row per frame.
+type timingsCSV struct {
+	file   *os.File
+	writer *bufio.Writer
+}
+
+func newTimingsCSV(path string) (*timingsCSV, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create timings file: %w", err)
+	}
+	c := &timingsCSV{file: file, writer: bufio.NewWriter(file)}
+	fmt.Fprintln(c.writer, "frame,time,frame_ms,cpu_ms,gpu_passes_ms,gpu_blit_ms")
+	return c, nil
+}
+
+func (c *timingsCSV) write(timing frameTiming) {
+	fmt.Fprintf(c.writer, "%d,%g,%.4f,%.4f,%.4f,%.4f\n", timing.frame, timing.time, timing.interval, timing.cpu, timing.passes, timing.blit)
+}
+
+func (c *timingsCSV) close() error {
+	if err := c.writer.Flush(); err != nil {
+		c.file.Close()
+		return fmt.Errorf("failed to write timings file: %w", err)
+	}
+	if err := c.file.Close(); err != nil {
+		return fmt.Errorf("failed to write timings file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// uniforms holds the locations of the inputs the render loop feeds to a user
+// shader. Locations are only valid for the program they were resolved from,
+// so they must be looked up again whenever the program is rebuilt.
+type uniforms struct {
+	iTime          int32
+	iSpeed         int32
+	iResolution    int32
+	iPosition      int32
+	iPositionFixed int32
+	iDirection     int32
+	iCameraRight   int32
+	iCameraUp      int32
+	iView          int32
+	iFov           int32
+	iSliders       int32
+
+	// Shadertoy inputs
+	iTimeDelta         int32
+	iFrameRate         int32
+	iFrame             int32
+	iChannelTime       int32
+	iMouse             int32
+	iDate              int32
+	iSampleRate        int32
+	iChannel           [channelCount]int32
+	iChannelResolution int32
+
+	iTile int32
+
+	// params are the locations of the shader's parameters, by name
+	params map[string]int32
+
+	// iResolution is a vec2 in gigashad shaders but a vec3 in Shadertoy ones
+	iResolutionVec3 bool
+	// iView can be declared as a mat3 or as a mat4 which includes iPosition
+	iViewMat3 bool
+}
+
+func getUniformLocations(program uint32, params []shaderParam) uniforms {
+	locations := uniforms{
+		iTime:          gl.GetUniformLocation(program, gl.Str("iTime\x00")),
+		iSpeed:         gl.GetUniformLocation(program, gl.Str("iSpeed\x00")),
+		iResolution:    gl.GetUniformLocation(program, gl.Str("iResolution\x00")),
+		iPosition:      gl.GetUniformLocation(program, gl.Str("iPosition\x00")),
+		iPositionFixed: gl.GetUniformLocation(program, gl.Str("iPositionFixed\x00")),
+		iDirection:     gl.GetUniformLocation(program, gl.Str("iDirection\x00")),
+		iCameraRight:   gl.GetUniformLocation(program, gl.Str("iCameraRight\x00")),
+		iCameraUp:      gl.GetUniformLocation(program, gl.Str("iCameraUp\x00")),
+		iView:          gl.GetUniformLocation(program, gl.Str("iView\x00")),
+		iFov:           gl.GetUniformLocation(program, gl.Str("iFov\x00")),
+		iSliders:       gl.GetUniformLocation(program, gl.Str("iSliders\x00")),
+
+		iTimeDelta:         gl.GetUniformLocation(program, gl.Str("iTimeDelta\x00")),
+		iFrameRate:         gl.GetUniformLocation(program, gl.Str("iFrameRate\x00")),
+		iFrame:             gl.GetUniformLocation(program, gl.Str("iFrame\x00")),
+		iChannelTime:       gl.GetUniformLocation(program, gl.Str("iChannelTime\x00")),
+		iMouse:             gl.GetUniformLocation(program, gl.Str("iMouse\x00")),
+		iDate:              gl.GetUniformLocation(program, gl.Str("iDate\x00")),
+		iSampleRate:        gl.GetUniformLocation(program, gl.Str("iSampleRate\x00")),
+		iChannelResolution: gl.GetUniformLocation(program, gl.Str("iChannelResolution\x00")),
+
+		iTile: gl.GetUniformLocation(program, gl.Str("iTile\x00")),
+
+		iResolutionVec3: getUniformType(program, "iResolution") == gl.FLOAT_VEC3,
+		iViewMat3:       getUniformType(program, "iView") == gl.FLOAT_MAT3,
+	}
+	locations.params = map[string]int32{}
+	for _, param := range params {
+		locations.params[param.name] = gl.GetUniformLocation(program, gl.Str(param.name+"\x00"))
+	}
+	for i := range locations.iChannel {
+		locations.iChannel[i] = gl.GetUniformLocation(program, gl.Str(fmt.Sprintf("iChannel%d\x00", i)))
+	}
+	return locations
+}
+
+// getUniformType returns the GLSL type of the active uniform called name, or
+// 0 if the program has no such uniform.
+func getUniformType(program uint32, name string) uint32 {
+	var count, maxLength int32
+	gl.GetProgramiv(program, gl.ACTIVE_UNIFORMS, &count)
+	gl.GetProgramiv(program, gl.ACTIVE_UNIFORM_MAX_LENGTH, &maxLength)
+	buffer := make([]uint8, maxLength+1)
+	for i := range uint32(count) {
+		var length, size int32
+		var xtype uint32
+		gl.GetActiveUniform(program, i, maxLength+1, &length, &size, &xtype, &buffer[0])
+		if string(buffer[:length]) == name {
+			return xtype
+		}
+	}
+	return 0
+}
+
+// frameState is everything the render loop feeds to the user shaders for a
+// single frame. Every pass of the frame receives the same values.
+type frameState struct {
+	time          float32
+	timeDelta     float32
+	frameRate     float32
+	frame         int
+	speed         float32
+	width, height int
+	position      vec3
+	positionFixed vec3
+	direction     vec3
+	right, up     vec3
+	fov           float32
+	sliders       [4]float32
+	mouse         [4]float32
+	date          [4]float32
+	params        map[string]float32
+	// tile is only set when rendering a poster in tiles
+	tile *tileTransform
+}
+
+// upload sets the uniforms of the currently bound program from state.
+func (u uniforms) upload(state frameState) {
+	gl.Uniform1f(u.iTime, state.time)
+	gl.Uniform1f(u.iSpeed, state.speed)
+	if u.iResolutionVec3 {
+		gl.Uniform3f(u.iResolution, float32(state.width), float32(state.height), 1)
+	} else {
+		gl.Uniform2f(u.iResolution, float32(state.width), float32(state.height))
+	}
+	gl.Uniform3f(u.iPosition, state.position.x, state.position.y, state.position.z)
+	gl.Uniform3f(u.iPositionFixed, state.positionFixed.x, state.positionFixed.y, state.positionFixed.z)
+	gl.Uniform3f(u.iDirection, state.direction.x, state.direction.y, state.direction.z)
+	gl.Uniform3f(u.iCameraRight, state.right.x, state.right.y, state.right.z)
+	gl.Uniform3f(u.iCameraUp, state.up.x, state.up.y, state.up.z)
+	// The view matrix takes camera space to world space, column by column
+	if u.iViewMat3 {
+		view := [9]float32{
+			state.right.x, state.right.y, state.right.z,
+			state.up.x, state.up.y, state.up.z,
+			state.direction.x, state.direction.y, state.direction.z,
+		}
+		gl.UniformMatrix3fv(u.iView, 1, false, &view[0])
+	} else {
+		view := [16]float32{
+			state.right.x, state.right.y, state.right.z, 0,
+			state.up.x, state.up.y, state.up.z, 0,
+			state.direction.x, state.direction.y, state.direction.z, 0,
+			state.position.x, state.position.y, state.position.z, 1,
+		}
+		gl.UniformMatrix4fv(u.iView, 1, false, &view[0])
+	}
+	gl.Uniform1f(u.iFov, state.fov)
+	gl.Uniform4fv(u.iSliders, 1, &state.sliders[0])
+
+	gl.Uniform1f(u.iTimeDelta, state.timeDelta)
+	gl.Uniform1f(u.iFrameRate, state.frameRate)
+	gl.Uniform1i(u.iFrame, int32(state.frame))
+	channelTime := [channelCount]float32{state.time, state.time, state.time, state.time}
+	gl.Uniform1fv(u.iChannelTime, channelCount, &channelTime[0])
+	gl.Uniform4fv(u.iMouse, 1, &state.mouse[0])
+	gl.Uniform4fv(u.iDate, 1, &state.date[0])
+	gl.Uniform1f(u.iSampleRate, shadertoySampleRate)
+
+	for name, location := range u.params {
+		if value, ok := state.params[name]; ok {
+			gl.Uniform1f(location, value)
+		}
+	}
+
+	if state.tile != nil {
+		gl.Uniform3f(u.iTile, state.tile.offsetX, state.tile.offsetY, state.tile.scale)
+	} else {
+		gl.Uniform3f(u.iTile, 0, 0, 1)
+	}
+}
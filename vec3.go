@@ -1,6 +1,8 @@
 package main
 
 import (
+	"encoding/json"
+
 	"github.com/chewxy/math32"
 )
 
@@ -44,3 +46,20 @@ func (v vec3) rotateAroundAxis(axis vec3, angle float32) vec3 {
 		add(axis.cross(v).scale(sin)).
 		add(axis.scale(axis.dot(v) * (1 - cos)))
 }
+
+func (v vec3) lerp(u vec3, t float32) vec3 {
+	return v.scale(1 - t).add(u.scale(t))
+}
+
+func (v vec3) MarshalJSON() ([]byte, error) {
+	return json.Marshal([3]float32{v.x, v.y, v.z})
+}
+
+func (v *vec3) UnmarshalJSON(data []byte) error {
+	var components [3]float32
+	if err := json.Unmarshal(data, &components); err != nil {
+		return err
+	}
+	v.x, v.y, v.z = components[0], components[1], components[2]
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"time"
+)
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// fileWatcher polls a set of files for modifications. Polling keeps it
+// dependency-free and copes with editors that save by renaming a temporary
+// file over the original.
+type fileWatcher struct {
+	stamps   map[string]fileStamp
+	interval time.Duration
+	lastPoll time.Time
+}
+
+func newFileWatcher(interval time.Duration, paths ...string) *fileWatcher {
+	w := &fileWatcher{interval: interval}
+	w.watch(paths...)
+	return w
+}
+
+// watch replaces the watched set with paths, recording their current state.
+func (w *fileWatcher) watch(paths ...string) {
+	w.stamps = make(map[string]fileStamp, len(paths))
+	for _, path := range paths {
+		w.stamps[path] = stampFile(path)
+	}
+	w.lastPoll = time.Now()
+}
+
+// changes returns the watched files modified since the last call. Files are
+// only stat'ed once per interval, so it is cheap to call every frame. A file
+// that is temporarily missing (mid-save) is not reported until it reappears.
+func (w *fileWatcher) changes() []string {
+	if time.Since(w.lastPoll) < w.interval {
+		return nil
+	}
+	w.lastPoll = time.Now()
+
+	var changed []string
+	for path, previous := range w.stamps {
+		current := stampFile(path)
+		if current.modTime.IsZero() {
+			continue
+		}
+		if current != previous {
+			w.stamps[path] = current
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
+
+func stampFile(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{info.ModTime(), info.Size()}
+}